@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/bytedance/sonic"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/utils"
+	"github.com/zohu/zwx/wxcpt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+func cmdList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	_ = fs.Parse(args)
+	appids := zwx.Appids()
+	sort.Strings(appids)
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APPID\tTYPE\tMAIN_APPID\tEXPIRE_IN\tRETRY")
+	for _, appid := range appids {
+		c, err := zwx.LoadApp(appid)
+		if err != nil {
+			_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\t%v\n", appid, err)
+			continue
+		}
+		app := c.Info()
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", app.Appid, app.AppType, app.MainAppid, expireIn(app.ExpireTime), app.Retry)
+	}
+	return w.Flush()
+}
+
+func cmdShow(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	appid := fs.String("appid", "", "appid")
+	_ = fs.Parse(args)
+	c, err := zwx.LoadApp(*appid)
+	if err != nil {
+		return err
+	}
+	app := c.Info()
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "appid\t%s\n", app.Appid)
+	_, _ = fmt.Fprintf(w, "app_type\t%s\n", app.AppType)
+	_, _ = fmt.Fprintf(w, "main_appid\t%s\n", app.MainAppid)
+	_, _ = fmt.Fprintf(w, "agent_id\t%s\n", app.AgentId)
+	_, _ = fmt.Fprintf(w, "component_appid\t%s\n", app.ComponentAppid)
+	_, _ = fmt.Fprintf(w, "app_secret\t%s\n", utils.Mask(app.AppSecret))
+	_, _ = fmt.Fprintf(w, "token\t%s\n", utils.Mask(app.Token))
+	_, _ = fmt.Fprintf(w, "encoding_aes_key\t%s\n", utils.Mask(app.EncodingAesKey))
+	_, _ = fmt.Fprintf(w, "notify_uri\t%s\n", app.NotifyUri)
+	_, _ = fmt.Fprintf(w, "access_token\t%s\n", utils.Mask(app.AccessToken))
+	_, _ = fmt.Fprintf(w, "js_ticket\t%s\n", utils.Mask(app.JsTicket))
+	_, _ = fmt.Fprintf(w, "card_ticket\t%s\n", utils.Mask(app.CardTicket))
+	_, _ = fmt.Fprintf(w, "agent_ticket\t%s\n", utils.Mask(app.AgentTicket))
+	_, _ = fmt.Fprintf(w, "expire_time\t%s (%s)\n", app.ExpireTime.Format(time.RFC3339), expireIn(app.ExpireTime))
+	_, _ = fmt.Fprintf(w, "refresh_time\t%s\n", app.RefreshTime.Format(time.RFC3339))
+	_, _ = fmt.Fprintf(w, "retry\t%s\n", app.Retry)
+	_, _ = fmt.Fprintf(w, "breaker\t%s\n", c.BreakerState())
+	return w.Flush()
+}
+
+// appFlags 创建/更新共用的参数
+func appFlags(fs *flag.FlagSet) *zwx.App {
+	app := new(zwx.App)
+	fs.StringVar((*string)(&app.AppType), "type", "", "应用类型，参考zwx.AppType")
+	fs.StringVar(&app.Appid, "appid", "", "appid/corpid/mchid")
+	fs.StringVar(&app.AppSecret, "secret", "", "secret")
+	fs.StringVar(&app.MainAppid, "main-appid", "", "关联的主appid")
+	fs.StringVar(&app.AgentId, "agent-id", "", "企业微信应用的agentid")
+	fs.StringVar(&app.ComponentAppid, "component-appid", "", "代授权的第三方平台appid")
+	fs.StringVar(&app.Token, "token", "", "消息token")
+	fs.StringVar(&app.EncodingAesKey, "aes-key", "", "消息EncodingAesKey")
+	fs.StringVar(&app.NotifyUri, "notify-uri", "", "微信支付回调地址")
+	return app
+}
+
+func cmdCreate(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	app := appFlags(fs)
+	_ = fs.Parse(args)
+	if err := zwx.CreateApp(*app); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(stdout, "app %s created\n", app.Appid)
+	return nil
+}
+
+func cmdUpdate(args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	app := appFlags(fs)
+	_ = fs.Parse(args)
+	c, err := zwx.LoadApp(app.Appid)
+	if err != nil {
+		return err
+	}
+	// 仅覆盖显式传入的参数
+	old := c.Info()
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "type":
+			old.AppType = app.AppType
+		case "secret":
+			old.AppSecret = app.AppSecret
+		case "main-appid":
+			old.MainAppid = app.MainAppid
+		case "agent-id":
+			old.AgentId = app.AgentId
+		case "component-appid":
+			old.ComponentAppid = app.ComponentAppid
+		case "token":
+			old.Token = app.Token
+		case "aes-key":
+			old.EncodingAesKey = app.EncodingAesKey
+		case "notify-uri":
+			old.NotifyUri = app.NotifyUri
+		}
+	})
+	if err = zwx.UpdateApp(old); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(stdout, "app %s updated\n", app.Appid)
+	return nil
+}
+
+func cmdDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	appid := fs.String("appid", "", "appid")
+	_ = fs.Parse(args)
+	if _, err := zwx.LoadApp(*appid); err != nil {
+		return err
+	}
+	zwx.DeleteApp(*appid)
+	_, _ = fmt.Fprintf(stdout, "app %s deleted\n", *appid)
+	return nil
+}
+
+func cmdRefresh(args []string) error {
+	fs := flag.NewFlagSet("refresh", flag.ExitOnError)
+	appid := fs.String("appid", "", "appid")
+	_ = fs.Parse(args)
+	c, err := zwx.LoadApp(*appid)
+	if err != nil {
+		return err
+	}
+	c.ResetBreaker()
+	c.NewAccessToken()
+	if c, err = zwx.LoadApp(*appid); err != nil {
+		return err
+	}
+	app := c.Info()
+	if app.AccessToken == "" || app.ExpireTime.Before(utils.Now()) {
+		return fmt.Errorf("app %s refresh failed, retry %s", app.Appid, app.Retry)
+	}
+	_, _ = fmt.Fprintf(stdout, "app %s refreshed, expire in %s\n", app.Appid, expireIn(app.ExpireTime))
+	return nil
+}
+
+func cmdTicket(args []string) error {
+	fs := flag.NewFlagSet("ticket", flag.ExitOnError)
+	appid := fs.String("appid", "", "appid")
+	card := fs.Bool("card", false, "打印卡券ticket")
+	agent := fs.Bool("agent", false, "打印企业微信应用的agent_config ticket")
+	_ = fs.Parse(args)
+	c, err := zwx.LoadApp(*appid)
+	if err != nil {
+		return err
+	}
+	ticket := c.JsTicket()
+	if *card {
+		ticket = c.CardTicket()
+	}
+	if *agent {
+		ticket = c.AgentTicket()
+	}
+	if ticket == "" {
+		return fmt.Errorf("app %s has no ticket", *appid)
+	}
+	_, _ = fmt.Fprintln(stdout, ticket)
+	return nil
+}
+
+func cmdVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	appid := fs.String("appid", "", "appid")
+	signature := fs.String("signature", "", "明文模式的signature")
+	msgSignature := fs.String("msg-signature", "", "企业微信的msg_signature")
+	timestamp := fs.String("timestamp", "", "timestamp")
+	nonce := fs.String("nonce", "", "nonce")
+	echostr := fs.String("echostr", "", "echostr")
+	_ = fs.Parse(args)
+	c, err := zwx.LoadApp(*appid)
+	if err != nil {
+		return err
+	}
+	if *msgSignature != "" {
+		cpt := wxcpt.NewBizMsgCrypt(c.NotifyToken(), c.NotifyEncodingAesKey(), c.AppidMain())
+		echo, err := cpt.VerifyURL(*msgSignature, *timestamp, *nonce, *echostr)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(stdout, "signature ok, echo: %s\n", echo)
+		return nil
+	}
+	if utils.Signature(c.NotifyToken(), *timestamp, *nonce) != *signature {
+		return errors.New("signature not equal")
+	}
+	_, _ = fmt.Fprintf(stdout, "signature ok, echo: %s\n", *echostr)
+	return nil
+}
+
+func cmdDecrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	appid := fs.String("appid", "", "appid")
+	msgSignature := fs.String("msg-signature", "", "msg_signature")
+	timestamp := fs.String("timestamp", "", "timestamp")
+	nonce := fs.String("nonce", "", "nonce")
+	file := fs.String("file", "-", "回调报文文件，-表示标准输入")
+	_ = fs.Parse(args)
+	c, err := zwx.LoadApp(*appid)
+	if err != nil {
+		return err
+	}
+	body, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	cpt := wxcpt.NewBizMsgCrypt(c.NotifyToken(), c.NotifyEncodingAesKey(), c.AppidMain())
+	var msg []byte
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '{' {
+		recv := new(wxcpt.BizMsgRecv)
+		if err = sonic.Unmarshal(body, recv); err != nil {
+			return err
+		}
+		msg, err = cpt.DecryptMsg(*msgSignature, *timestamp, *nonce, recv)
+	} else {
+		msg, err = cpt.DecryptMsgFromBinary(*msgSignature, *timestamp, *nonce, body)
+	}
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(stdout, string(msg))
+	return nil
+}
+
+// cmdExport 导出声明式配置，不含token等内部维护字段
+func cmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("o", "-", "输出文件，按扩展名选择yaml/json/toml，-表示标准输出json")
+	_ = fs.Parse(args)
+	appids := zwx.Appids()
+	sort.Strings(appids)
+	apps := make([]zwx.App, 0, len(appids))
+	for _, appid := range appids {
+		c, err := zwx.LoadApp(appid)
+		if err != nil {
+			return err
+		}
+		apps = append(apps, c.Info())
+	}
+	if *out == "-" {
+		d, err := zwx.MarshalAppConfig(apps, zwx.ConfigFormatJson)
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(append(d, '\n'))
+		return err
+	}
+	if err := zwx.SaveAppConfig(*out, apps); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(stdout, "%d apps exported to %s\n", len(apps), *out)
+	return nil
+}
+
+// cmdImport 按声明式配置创建、更新APP，-prune时删除配置中不存在的APP
+func cmdImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("f", "", "配置文件(yaml/json/toml)")
+	prune := fs.Bool("prune", false, "删除配置中不存在的APP")
+	dryRun := fs.Bool("dry-run", false, "仅打印计划")
+	_ = fs.Parse(args)
+	cfg, err := zwx.LoadAppConfig(*file)
+	if err != nil {
+		return err
+	}
+	cfg.Prune = *prune
+	plan, err := zwx.Reconcile(cfg, *dryRun)
+	_, _ = fmt.Fprintln(stdout, plan.String())
+	return err
+}
+
+func readInput(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+func expireIn(t time.Time) string {
+	d := t.Sub(utils.Now())
+	if d <= 0 {
+		return "expired"
+	}
+	return d.Truncate(time.Second).String()
+}
+
+func cmdMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "仅列出待执行的迁移")
+	verify := fs.Bool("verify", false, "仅校验存储结构")
+	_ = fs.Parse(args)
+	if *verify {
+		if err := zwx.VerifySchema(); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(stdout, "zwx schema v%d verified\n", zwx.SchemaVersion())
+		return nil
+	}
+	report, err := zwx.Migrate(*dryRun)
+	_, _ = fmt.Fprintln(stdout, report.String())
+	return err
+}
+
+func cmdBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("o", "-", "输出文件，-表示标准输出")
+	secrets := fs.Bool("secrets", true, "包含secret、token、EncodingAesKey")
+	tokens := fs.Bool("tokens", false, "包含access_token等在线凭证")
+	passphraseEnv := fs.String("passphrase-env", "", "从该环境变量读取加密口令")
+	_ = fs.Parse(args)
+	opts := []zwx.BackupOption{zwx.WithSecrets(*secrets), zwx.WithTokens(*tokens)}
+	if *passphraseEnv != "" {
+		opts = append(opts, zwx.WithPassphrase(os.Getenv(*passphraseEnv)))
+	}
+	if *out == "-" {
+		return zwx.ExportApps(stdout, opts...)
+	}
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return zwx.ExportApps(f, opts...)
+}
+
+func cmdRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	file := fs.String("f", "-", "备份文件，-表示标准输入")
+	mode := fs.String("mode", string(zwx.ImportModeSkip), "冲突策略 skip/overwrite/fail")
+	passphraseEnv := fs.String("passphrase-env", "", "从该环境变量读取解密口令")
+	_ = fs.Parse(args)
+	body, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	var opts []zwx.BackupOption
+	if *passphraseEnv != "" {
+		opts = append(opts, zwx.WithPassphrase(os.Getenv(*passphraseEnv)))
+	}
+	report, err := zwx.ImportApps(bytes.NewReader(body), zwx.ImportMode(*mode), opts...)
+	if report != nil {
+		_, _ = fmt.Fprintln(stdout, report.String())
+	}
+	return err
+}
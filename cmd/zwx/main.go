@@ -0,0 +1,111 @@
+// Command zwx 托管APP与token的运维工具
+//
+//	zwx [-redis addr] [-prefix prefix] <command> [flags]
+//
+// 存储连接也可通过环境变量 ZWX_REDIS、ZWX_REDIS_PASSWORD、ZWX_REDIS_DB、ZWX_PREFIX 配置
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/zohu/zwx"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stdout 命令输出
+var stdout io.Writer = os.Stdout
+
+type command struct {
+	usage string
+	run   func(args []string) error
+	// 修改APP的命令，存储为空时与服务端一致写入结构版本，其余命令不写存储结构
+	write bool
+}
+
+var commands = map[string]command{
+	"list":    {"列出已托管的APP", cmdList, false},
+	"show":    {"查看APP详情，密钥脱敏", cmdShow, false},
+	"create":  {"创建并托管APP", cmdCreate, true},
+	"update":  {"更新APP配置", cmdUpdate, true},
+	"delete":  {"停止托管APP", cmdDelete, true},
+	"refresh": {"强制刷新access_token", cmdRefresh, true},
+	"ticket":  {"打印当前JS ticket", cmdTicket, false},
+	"verify":  {"校验回调URL签名", cmdVerify, false},
+	"decrypt": {"解密抓取到的回调报文", cmdDecrypt, false},
+	"export":  {"导出APP配置", cmdExport, false},
+	"import":  {"导入APP配置", cmdImport, true},
+	"migrate": {"执行或校验存储结构迁移", cmdMigrate, false},
+	"backup":  {"备份全部APP，可加密", cmdBackup, false},
+	"restore": {"从备份恢复APP", cmdRestore, true},
+}
+
+func main() {
+	fs := flag.NewFlagSet("zwx", flag.ExitOnError)
+	addr := fs.String("redis", env("ZWX_REDIS", "127.0.0.1:6379"), "redis地址，多个用逗号分隔")
+	password := fs.String("redis-password", env("ZWX_REDIS_PASSWORD", ""), "redis密码")
+	db := fs.Int("redis-db", envInt("ZWX_REDIS_DB", 0), "redis db")
+	prefix := fs.String("prefix", env("ZWX_PREFIX", ""), "存储器前缀，与服务端Options.StoragePrefix一致")
+	debug := fs.Bool("debug", false, "打印请求日志")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "usage: zwx [flags] <command> [command flags]\n\ncommands:\n")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			_, _ = fmt.Fprintf(fs.Output(), "  %-8s %s\n", name, commands[name].usage)
+		}
+		_, _ = fmt.Fprintf(fs.Output(), "\nflags:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(os.Args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(2)
+	}
+	start(cmd, &zwx.Options{
+		Debug: *debug,
+		RedisClient: redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs:    strings.Split(*addr, ","),
+			Password: *password,
+			DB:       *db,
+		}),
+		StoragePrefix: *prefix,
+	})
+	if err := cmd.run(fs.Args()[1:]); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "zwx %s: %v\n", fs.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+// start 初始化zwx，不启动后台刷新，只读命令不迁移存储结构
+func start(cmd command, options *zwx.Options) {
+	options.DisableAutoRefresh = true
+	options.DisableMigrate = !cmd.write
+	zwx.New(options)
+}
+
+func env(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/internal/memstore"
+	"github.com/zohu/zwx/internal/zwxtest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	s := memstore.New()
+	t.Cleanup(zwx.Shutdown)
+	dir := t.TempDir()
+	config := filepath.Join(dir, "apps.yaml")
+	if err := os.WriteFile(config, []byte("apps:\n  - app_type: \"8\"\n    appid: wxcfg\n    app_secret: cfg-secret-0001\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		args       []string
+		want       []string
+		notWant    []string
+		wantErr    string
+		wantSchema int
+	}{
+		{name: "list on empty storage keeps schema unset", args: []string{"list"}, want: []string{"APPID"}, wantSchema: 0},
+		{name: "create writes schema", args: []string{"create", "-type", "8", "-appid", "wx1", "-secret", "secret-0123456789"}, want: []string{"app wx1 created"}, wantSchema: zwx.LatestSchemaVersion()},
+		{name: "show masks secrets", args: []string{"show", "-appid", "wx1"}, want: []string{"secr****6789"}, notWant: []string{"secret-0123456789"}, wantSchema: zwx.LatestSchemaVersion()},
+		{name: "update only given flags", args: []string{"update", "-appid", "wx1", "-token", "tok"}, want: []string{"app wx1 updated"}, wantSchema: zwx.LatestSchemaVersion()},
+		{name: "export declared fields", args: []string{"export"}, want: []string{`"appid": "wx1"`, `"token": "tok"`}, notWant: []string{"access_token", "expire_time"}, wantSchema: zwx.LatestSchemaVersion()},
+		{name: "import dry run keeps undeclared", args: []string{"import", "-f", config, "-dry-run"}, want: []string{"1 to create", "0 to delete", "+ create wxcfg"}, wantSchema: zwx.LatestSchemaVersion()},
+		{name: "import with prune", args: []string{"import", "-f", config, "-prune"}, want: []string{"1 to create", "1 to delete", "- delete wx1"}, wantSchema: zwx.LatestSchemaVersion()},
+		{name: "list after import", args: []string{"list"}, want: []string{"wxcfg"}, notWant: []string{"wx1 "}, wantSchema: zwx.LatestSchemaVersion()},
+		{name: "missing app", args: []string{"show", "-appid", "nope"}, wantErr: "nope", wantSchema: zwx.LatestSchemaVersion()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			stdout = &buf
+			t.Cleanup(func() { stdout = os.Stdout })
+			cmd := commands[tt.args[0]]
+			start(cmd, &zwx.Options{Storage: s, Logger: zwxtest.Logger{T: t}})
+			err := cmd.run(tt.args[1:])
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("output missing %q:\n%s", w, out)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(out, w) {
+					t.Errorf("output contains %q:\n%s", w, out)
+				}
+			}
+			if v := zwx.SchemaVersion(); v != tt.wantSchema {
+				t.Errorf("schema = v%d, want v%d", v, tt.wantSchema)
+			}
+		})
+	}
+}
@@ -36,6 +36,10 @@ type App struct {
 	AppSecret string `json:"app_secret" validate:"required"`
 	// 订阅号关联的服务号、小程序关联的公众号、企业微信应用关联的企业微信，微信支付关联的业务appid
 	MainAppid string `json:"main_appid"`
+	// 企业微信应用的agentid，用于wx.agentConfig、发送应用消息
+	AgentId string `json:"agent_id"`
+	// 第三方平台代授权的公众号、小程序所属的开放平台appid，此时AppSecret为authorizer_refresh_token
+	ComponentAppid string `json:"component_appid"`
 	// 公众号消息相关的token，微信支付的证书序列号
 	Token string `json:"token" validate:"required_if=AppType 10"`
 	// 公众号消息相关的EncodingAesKey，微信支付的私钥证书文本内容
@@ -48,10 +52,24 @@ type App struct {
 	JsTicket string `json:"js_ticket"`
 	// DO NOT EDIT, 内部维护字段
 	CardTicket string `json:"card_ticket"`
+	// DO NOT EDIT, 内部维护字段，企业微信应用的agent_config ticket
+	AgentTicket string `json:"agent_ticket"`
 	// DO NOT EDIT, 内部维护字段
 	ExpireTime time.Time `json:"expire_time"`
 	// DO NOT EDIT, 内部维护字段
 	Retry string `json:"retry"`
+	// DO NOT EDIT, 内部维护字段
+	RefreshTime time.Time `json:"refresh_time"`
+	// DO NOT EDIT, 内部维护字段，所属租户，通过ForTenant创建时写入
+	Tenant string `json:"tenant"`
+	// DO NOT EDIT, 内部维护字段，熔断状态
+	Breaker BreakerState `json:"breaker"`
+	// DO NOT EDIT, 内部维护字段，连续鉴权失败次数
+	AuthFailures string `json:"auth_failures"`
+	// DO NOT EDIT, 内部维护字段，最近一次鉴权失败的错误码
+	AuthErrcode string `json:"auth_errcode"`
+	// DO NOT EDIT, 内部维护字段，熔断打开时间
+	BreakerOpenedAt time.Time `json:"breaker_opened_at"`
 }
 
 type Context struct {
@@ -63,12 +81,24 @@ type Context struct {
 func (c *Context) Appid() string {
 	return c.app.Appid
 }
+func (c *Context) Info() App {
+	return *c.app
+}
+func (c *Context) Tenant() string {
+	return c.app.Tenant
+}
 func (c *Context) AppidMain() string {
 	if c.app.MainAppid != "" {
 		return c.app.MainAppid
 	}
 	return c.app.Appid
 }
+func (c *Context) AgentId() string {
+	return c.app.AgentId
+}
+func (c *Context) ComponentAppid() string {
+	return c.app.ComponentAppid
+}
 func (c *Context) AppSecret() string {
 	return c.app.AppSecret
 }
@@ -108,6 +138,11 @@ func (c *Context) IsDebug() bool {
 func (c *Context) Logger() Logger {
 	return c.logger
 }
+
+// Storage 带全局前缀的存储，供子包保存幂等标记等临时数据
+func (c *Context) Storage() Storage {
+	return c.storage
+}
 func (c *Context) NotifyToken() string {
 	return c.app.Token
 }
@@ -118,12 +153,16 @@ func (c *Context) NotifyUri() string {
 	return c.app.NotifyUri
 }
 func (c *Context) AccessToken() string {
-	if c.app.ExpireTime.Before(time.Now()) {
+	if c.lazyToken {
+		c.markActive()
+	}
+	if c.app.ExpireTime.Before(utils.Now()) {
 		c.app.AccessToken = ""
 		c.app.JsTicket = ""
 		c.app.CardTicket = ""
+		c.app.AgentTicket = ""
 	}
-	if c.app.AccessToken == "" {
+	if c.app.AccessToken == "" && c.BreakerState() != BreakerOpen {
 		c.NewAccessToken()
 	}
 	return c.app.AccessToken
@@ -134,33 +173,40 @@ func (c *Context) JsTicket() string {
 func (c *Context) CardTicket() string {
 	return c.app.CardTicket
 }
+func (c *Context) AgentTicket() string {
+	return c.app.AgentTicket
+}
 func (c *Context) NewAccessToken() {
 	c.Lock()
 	defer c.Unlock()
-	if c.app.ExpireTime.Before(time.Now()) {
+	if c.app.ExpireTime.Before(utils.Now()) {
 		c.app.AccessToken = ""
 		c.app.JsTicket = ""
 		c.app.CardTicket = ""
+		c.app.AgentTicket = ""
+	}
+	allow, done := c.breakerAllow()
+	if !allow {
+		c.logger.Debugf("%s circuit open, skip refresh", c.Appid())
+		return
 	}
+	defer done()
+	errcode := 0
 	switch c.app.AppType {
 	case TypeWxMpServe:
-		c.newMpToken()
-		c.newMpTicket(TicketTypeJs)
-		c.newMpTicket(TicketTypeCard)
+		errcode = c.newMpToken()
 	case TypeWxMpSubscribe:
-		c.newMpToken()
-		c.newMpTicket(TicketTypeJs)
+		errcode = c.newMpToken()
 	case TypeWxWork:
-		c.newWorkToken()
-		c.newWorkTicket()
+		errcode = c.newWorkToken()
 	case TypeWxApp:
-		c.newMpToken()
+		errcode = c.newMpToken()
 	case TypeWxMiniApp:
-		c.newMpToken()
+		errcode = c.newMpToken()
 	case TypeWxMiniGame:
 		break
 	case TypeWxOpen:
-		break
+		errcode = c.newComponentToken()
 	case TypeWxVideo:
 		break
 	case TypeWxStore:
@@ -171,15 +217,42 @@ func (c *Context) NewAccessToken() {
 		c.logger.Errorf("unknown app type: %s", c.app.AppType)
 		return
 	}
+	if errcode == errcodeNotReady {
+		return
+	}
+	c.newTickets()
 	wxl.Lock()
 	defer wxl.Unlock()
-	if c.app.AccessToken == "" {
+	if errcode != 0 || c.app.AccessToken == "" {
 		c.storage.HIncrBy(PrefixApp.Key(c.Appid()), "retry", 1)
+		c.breakerFailure(errcode)
 	} else {
+		c.app.Retry = "0"
+		c.app.RefreshTime = utils.Now()
+		c.breakerReset()
 		c.storage.HSet(PrefixApp.Key(c.Appid()), utils.StructToMap(c.app))
 	}
 }
 
+// SetAccessToken
+// @Description: 使用外部获取的access_token，如第三方平台授权时返回的authorizer_access_token，同时刷新ticket，无需再次请求token
+// @receiver c
+// @param token
+// @param expireTime
+func (c *Context) SetAccessToken(token string, expireTime time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.app.AccessToken = token
+	c.app.ExpireTime = expireTime
+	c.newTickets()
+	wxl.Lock()
+	defer wxl.Unlock()
+	c.app.Retry = "0"
+	c.app.RefreshTime = utils.Now()
+	c.breakerReset()
+	c.storage.HSet(PrefixApp.Key(c.Appid()), utils.StructToMap(c.app))
+}
+
 // RetryAccessToken
 // @Description: 是否可以刷新token并重试(每个app每2分钟只能重试一次)
 // @receiver c
@@ -202,3 +275,6 @@ func (c *Context) RetryAccessToken(errcode int) bool {
 func (c *Context) Error(action, message string) error {
 	return fmt.Errorf("[%s] %s failed: %s", c.Appid(), action, message)
 }
+func (c *Context) ErrorWith(action string, err error) error {
+	return fmt.Errorf("[%s] %s failed: %w", c.Appid(), action, err)
+}
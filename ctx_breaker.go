@@ -0,0 +1,141 @@
+package zwx
+
+import (
+	"fmt"
+	"github.com/zohu/zwx/utils"
+	"strconv"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // 正常
+	BreakerOpen     BreakerState = "open"      // 熔断，不再请求token，API调用直接返回CircuitOpenError
+	BreakerHalfOpen BreakerState = "half_open" // 冷却结束，允许一次探测
+)
+
+// authErrcodes 凭证类错误，重试无意义
+var authErrcodes = map[int]bool{
+	40001: true, // 无效的secret
+	40013: true, // 无效的appid/corpid
+	40091: true, // 企业微信secret无效
+	40125: true, // 无效的appsecret
+	40164: true, // 调用IP不在白名单
+	41002: true, // 缺少appid
+	41004: true, // 缺少secret
+	60020: true, // 企业微信调用IP不在白名单
+}
+
+// CircuitOpenError
+// @Description: APP凭证持续失效，熔断期间的API调用返回该错误
+type CircuitOpenError struct {
+	Appid    string
+	Errcode  int
+	OpenedAt time.Time
+	RetryAt  time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("[%s] circuit open since %s after auth errcode %d, retry at %s",
+		e.Appid, e.OpenedAt.Format(time.RFC3339), e.Errcode, e.RetryAt.Format(time.RFC3339))
+}
+
+// BreakerState
+// @Description: 当前熔断状态
+// @receiver c
+// @return BreakerState
+func (c *Context) BreakerState() BreakerState {
+	if c.app.Breaker != BreakerOpen {
+		return BreakerClosed
+	}
+	if utils.Now().Sub(c.app.BreakerOpenedAt) >= c.breakerCooldown {
+		return BreakerHalfOpen
+	}
+	return BreakerOpen
+}
+
+// CircuitError
+// @Description: 熔断中返回*CircuitOpenError，否则返回nil
+// @receiver c
+// @return error
+func (c *Context) CircuitError() error {
+	if c.BreakerState() != BreakerOpen {
+		return nil
+	}
+	errcode, _ := strconv.Atoi(c.app.AuthErrcode)
+	return &CircuitOpenError{
+		Appid:    c.Appid(),
+		Errcode:  errcode,
+		OpenedAt: c.app.BreakerOpenedAt,
+		RetryAt:  c.app.BreakerOpenedAt.Add(c.breakerCooldown),
+	}
+}
+
+// ResetBreaker
+// @Description: 手动关闭熔断，如客户已修复secret或白名单
+// @receiver c
+func (c *Context) ResetBreaker() {
+	wxl.Lock()
+	defer wxl.Unlock()
+	c.breakerReset()
+	c.storage.HSet(PrefixApp.Key(c.Appid()), c.breakerFields())
+	c.storage.Del(PrefixRetry.Key(c.Appid(), "probe"))
+}
+
+// breakerProbeTimeout 探测锁的最长持有时间，持有实例异常退出时到期释放
+const breakerProbeTimeout = time.Minute
+
+// breakerAllow 熔断时不请求，半开时多实例间同时只允许一次探测，返回的done在探测结束后调用以释放探测锁。
+// 探测结果：成功则关闭熔断；凭证类错误则重新熔断并重新冷却；其它错误(网络、限流等)不能说明凭证状态，保持半开，下次刷新继续探测
+func (c *Context) breakerAllow() (allow bool, done func()) {
+	switch c.BreakerState() {
+	case BreakerOpen:
+		return false, nil
+	case BreakerHalfOpen:
+		key := PrefixRetry.Key(c.Appid(), "probe")
+		if !c.storage.SetNX(key, "probing", min(c.breakerCooldown, breakerProbeTimeout)) {
+			return false, nil
+		}
+		return true, func() { c.storage.Del(key) }
+	default:
+		return true, func() {}
+	}
+}
+
+// breakerFailure 记录失败，非凭证类错误不计数，需持有wxl
+func (c *Context) breakerFailure(errcode int) {
+	if !authErrcodes[errcode] {
+		return
+	}
+	failures, _ := strconv.Atoi(c.app.AuthFailures)
+	failures++
+	c.app.AuthFailures = strconv.Itoa(failures)
+	c.app.AuthErrcode = strconv.Itoa(errcode)
+	if failures >= c.breakerThreshold || c.BreakerState() == BreakerHalfOpen {
+		c.app.Breaker = BreakerOpen
+		c.app.BreakerOpenedAt = utils.Now()
+		c.logger.Errorf("%s circuit open after %d auth failures, errcode %d", c.Appid(), failures, errcode)
+	}
+	c.storage.HSet(PrefixApp.Key(c.Appid()), c.breakerFields())
+}
+
+// breakerReset 成功后关闭熔断，需持有wxl
+func (c *Context) breakerReset() {
+	if c.app.Breaker == BreakerOpen {
+		c.logger.Infof("%s circuit closed", c.Appid())
+	}
+	c.app.Breaker = BreakerClosed
+	c.app.AuthFailures = "0"
+	c.app.AuthErrcode = ""
+	c.app.BreakerOpenedAt = time.Time{}
+}
+
+func (c *Context) breakerFields() map[string]string {
+	return map[string]string{
+		"breaker":           string(c.app.Breaker),
+		"auth_failures":     c.app.AuthFailures,
+		"auth_errcode":      c.app.AuthErrcode,
+		"breaker_opened_at": c.app.BreakerOpenedAt.Format(time.RFC3339Nano),
+	}
+}
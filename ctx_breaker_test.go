@@ -0,0 +1,116 @@
+package zwx
+
+import (
+	"errors"
+	"github.com/zohu/zwx/utils"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	setupTest(t)
+	base := time.Unix(1700000000, 0)
+	t.Cleanup(func() { utils.SetProvider(nil) })
+	setClock := func(d time.Duration) { utils.SetProvider(utils.NewSeededProvider(1, base.Add(d))) }
+	setClock(0)
+	if err := CreateApp(testApp("wx1")); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadApp("wx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := func(errcode int) func(t *testing.T) {
+		return func(t *testing.T) {
+			wxl.Lock()
+			defer wxl.Unlock()
+			c.breakerFailure(errcode)
+		}
+	}
+	succeed := func(t *testing.T) {
+		wxl.Lock()
+		defer wxl.Unlock()
+		c.breakerReset()
+	}
+	// probe 模拟NewAccessToken：获得探测锁后执行outcome并释放
+	probe := func(outcome func(t *testing.T)) func(t *testing.T) {
+		return func(t *testing.T) {
+			allow, done := c.breakerAllow()
+			if !allow {
+				t.Fatal("probe not allowed")
+			}
+			if again, _ := c.breakerAllow(); again {
+				t.Error("concurrent probe allowed")
+			}
+			outcome(t)
+			done()
+		}
+	}
+	wait := func(t *testing.T) {}
+	cooldown := wx.breakerCooldown
+	tests := []struct {
+		name      string
+		clock     time.Duration
+		action    func(t *testing.T)
+		wantState BreakerState
+		wantAllow bool
+	}{
+		{"auth failures below threshold", 0, func(t *testing.T) {
+			for range wx.breakerThreshold - 1 {
+				fail(40001)(t)
+			}
+		}, BreakerClosed, true},
+		{"non-auth failure not counted", 0, fail(-1), BreakerClosed, true},
+		{"threshold opens", 0, fail(40001), BreakerOpen, false},
+		{"still open before cooldown", cooldown - time.Second, wait, BreakerOpen, false},
+		{"half open after cooldown", cooldown, wait, BreakerHalfOpen, true},
+		{"probe non-auth error stays half open", cooldown, probe(fail(45009)), BreakerHalfOpen, true},
+		{"probe auth error reopens", cooldown, probe(fail(40125)), BreakerOpen, false},
+		{"reopened cooldown restarts", 2*cooldown - time.Second, wait, BreakerOpen, false},
+		{"half open again", 2 * cooldown, wait, BreakerHalfOpen, true},
+		{"probe success closes", 2 * cooldown, probe(succeed), BreakerClosed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setClock(tt.clock)
+			tt.action(t)
+			if got := c.BreakerState(); got != tt.wantState {
+				t.Fatalf("state = %s, want %s", got, tt.wantState)
+			}
+			var open *CircuitOpenError
+			if got := errors.As(c.CircuitError(), &open); got != (tt.wantState == BreakerOpen) {
+				t.Errorf("circuit error = %v", c.CircuitError())
+			}
+			allow, done := c.breakerAllow()
+			if allow != tt.wantAllow {
+				t.Errorf("allow = %v, want %v", allow, tt.wantAllow)
+			}
+			if allow {
+				done()
+			}
+		})
+	}
+}
+
+func TestResetBreaker(t *testing.T) {
+	setupTest(t)
+	if err := CreateApp(testApp("wx1")); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadApp("wx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wxl.Lock()
+	for range wx.breakerThreshold {
+		c.breakerFailure(40001)
+	}
+	wxl.Unlock()
+	if c, _ = LoadApp("wx1"); c.BreakerState() != BreakerOpen {
+		t.Fatalf("stored state = %s, want open", c.BreakerState())
+	}
+	c.ResetBreaker()
+	if c, _ = LoadApp("wx1"); c.BreakerState() != BreakerClosed || c.app.AuthFailures != "0" {
+		t.Errorf("state = %s, failures = %s", c.BreakerState(), c.app.AuthFailures)
+	}
+}
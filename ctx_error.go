@@ -0,0 +1,50 @@
+package zwx
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ridRegexp 微信错误信息末尾携带的请求ID，如 "invalid credential rid: 6512a1b2-1a2b3c4d-5e6f7a8b"
+var ridRegexp = regexp.MustCompile(`rid:\s*([0-9A-Za-z-]+)`)
+
+// WxError
+// @Description: 微信接口返回的业务错误，Rid可通过GetRid查询请求详情
+type WxError struct {
+	Appid   string
+	Action  string
+	Errcode int
+	Errmsg  string
+	Rid     string
+}
+
+func (e *WxError) Error() string {
+	return fmt.Sprintf("[%s] %s failed: errcode %d, %s", e.Appid, e.Action, e.Errcode, e.Errmsg)
+}
+
+// ParseRid
+// @Description: 从errmsg中提取rid，没有则返回空
+// @param errmsg
+// @return string
+func ParseRid(errmsg string) string {
+	if m := ridRegexp.FindStringSubmatch(errmsg); len(m) == 2 {
+		return m[1]
+	}
+	return ""
+}
+
+// ErrorFrom
+// @Description: 将微信接口的错误响应包装为*WxError
+// @receiver c
+// @param action
+// @param resp
+// @return error
+func (c *Context) ErrorFrom(action string, resp WxResponse) error {
+	return &WxError{
+		Appid:   c.Appid(),
+		Action:  action,
+		Errcode: resp.Errcode,
+		Errmsg:  resp.Errmsg,
+		Rid:     ParseRid(resp.Errmsg),
+	}
+}
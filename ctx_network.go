@@ -0,0 +1,101 @@
+package zwx
+
+type CheckAction string
+type CheckOperator string
+
+const (
+	CheckActionDns  CheckAction = "dns"  // 做域名解析
+	CheckActionPing CheckAction = "ping" // 做ping检测
+	CheckActionAll  CheckAction = "all"  // 域名解析和ping检测
+)
+const (
+	CheckOperatorDefault  CheckOperator = "DEFAULT"  // 根据ip来选择运营商
+	CheckOperatorChinanet CheckOperator = "CHINANET" // 电信出口
+	CheckOperatorUnicom   CheckOperator = "UNICOM"   // 联通出口
+	CheckOperatorCap      CheckOperator = "CAP"      // 腾讯自建出口
+)
+
+type ResCallbackCheck struct {
+	WxResponse
+	Dns []struct {
+		Ip           string `json:"ip"`
+		RealOperator string `json:"real_operator"`
+	} `json:"dns"`
+	Ping []struct {
+		Ip           string `json:"ip"`
+		FromOperator string `json:"from_operator"`
+		PackageLoss  string `json:"package_loss"`
+		Time         string `json:"time"`
+	} `json:"ping"`
+}
+
+// CallbackCheck
+// @Description: 网络检测，检查微信服务器到回调地址的连通性
+// @receiver c
+// @param action
+// @param operator
+// @return *ResCallbackCheck
+// @return error
+func (c *Context) CallbackCheck(action CheckAction, operator CheckOperator) (*ResCallbackCheck, error) {
+	var resp ResCallbackCheck
+	if err := NewHttp(MethodPost, ApiCgiBin.WithPath("callback/check")).
+		Idempotent(true).
+		SetAccessTokenFrom(c).
+		SetJson(map[string]string{
+			"action":         string(action),
+			"check_operator": string(operator),
+		}).
+		BindJson(&resp).
+		Debug(c.debug, c.logger).
+		Do(); err != nil {
+		return nil, c.ErrorWith("callback_check", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.CallbackCheck(action, operator)
+		}
+		return nil, c.ErrorFrom("callback_check", resp.WxResponse)
+	}
+	return &resp, nil
+}
+
+type ResIpList struct {
+	WxResponse
+	IpList []string `json:"ip_list"`
+}
+
+// GetCallbackIp
+// @Description: 获取微信推送消息的服务器IP，用于回调地址的防火墙白名单
+// @receiver c
+// @return []string
+// @return error
+func (c *Context) GetCallbackIp() ([]string, error) {
+	return c.getIpList("getcallbackip")
+}
+
+// GetApiDomainIp
+// @Description: 获取微信API域名的IP，用于出口防火墙白名单
+// @receiver c
+// @return []string
+// @return error
+func (c *Context) GetApiDomainIp() ([]string, error) {
+	return c.getIpList("get_api_domain_ip")
+}
+
+func (c *Context) getIpList(path string) ([]string, error) {
+	var resp ResIpList
+	if err := NewHttp(MethodGet, ApiCgiBin.WithPath(path)).
+		SetAccessTokenFrom(c).
+		BindJson(&resp).
+		Debug(c.debug, c.logger).
+		Do(); err != nil {
+		return nil, c.ErrorWith(path, err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.getIpList(path)
+		}
+		return nil, c.ErrorFrom(path, resp.WxResponse)
+	}
+	return resp.IpList, nil
+}
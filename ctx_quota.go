@@ -0,0 +1,127 @@
+package zwx
+
+type ResQuota struct {
+	WxResponse
+	Quota struct {
+		DailyLimit int64 `json:"daily_limit"` // 当天该账号可调用该接口的次数
+		Used       int64 `json:"used"`        // 当天已经调用的次数
+		Remain     int64 `json:"remain"`      // 当天剩余调用次数
+	} `json:"quota"`
+	RateLimit struct {
+		CallCount     int64 `json:"call_count"`     // 周期内可调用数量
+		RefreshSecond int64 `json:"refresh_second"` // 更新周期，单位秒
+	} `json:"rate_limit"`
+	ComponentRateLimit struct {
+		CallCount     int64 `json:"call_count"`
+		RefreshSecond int64 `json:"refresh_second"`
+	} `json:"component_rate_limit"`
+}
+
+// GetQuota
+// @Description: 查询接口调用额度
+// @receiver c
+// @param cgiPath 接口路径，如 /cgi-bin/message/custom/send
+// @return *ResQuota
+// @return error
+func (c *Context) GetQuota(cgiPath string) (*ResQuota, error) {
+	var resp ResQuota
+	if err := NewHttp(MethodPost, ApiCgiBin.WithPath("openapi/quota/get")).
+		Idempotent(true).
+		SetAccessTokenFrom(c).
+		SetJson(map[string]string{"cgi_path": cgiPath}).
+		BindJson(&resp).
+		Debug(c.debug, c.logger).
+		Do(); err != nil {
+		return nil, c.ErrorWith("get_quota", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.GetQuota(cgiPath)
+		}
+		return nil, c.ErrorFrom("get_quota", resp.WxResponse)
+	}
+	return &resp, nil
+}
+
+// ClearQuota
+// @Description: 使用access_token重置接口调用次数，每月共10次
+// @receiver c
+// @return error
+func (c *Context) ClearQuota() error {
+	var resp WxResponse
+	if err := NewHttp(MethodPost, ApiCgiBin.WithPath("clear_quota")).
+		SetAccessTokenFrom(c).
+		SetJson(map[string]string{"appid": c.AppidMain()}).
+		BindJson(&resp).
+		Debug(c.debug, c.logger).
+		Do(); err != nil {
+		return c.ErrorWith("clear_quota", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.ClearQuota()
+		}
+		return c.ErrorFrom("clear_quota", resp)
+	}
+	return nil
+}
+
+// ClearQuotaBySecret
+// @Description: 使用AppSecret重置接口调用次数，不依赖access_token，可用于access_token本身超限的场景
+// @receiver c
+// @return error
+func (c *Context) ClearQuotaBySecret() error {
+	var resp WxResponse
+	// 请求体包含appsecret，不输出debug日志
+	if err := NewHttp(MethodPost, ApiCgiBin.WithPath("clear_quota/v2")).
+		SetJson(map[string]string{
+			"appid":     c.AppidMain(),
+			"appsecret": c.AppSecret(),
+		}).
+		BindJson(&resp).
+		Do(); err != nil {
+		return c.ErrorWith("clear_quota_v2", err)
+	}
+	if resp.Errcode != 0 {
+		return c.ErrorFrom("clear_quota_v2", resp)
+	}
+	return nil
+}
+
+type ResRid struct {
+	WxResponse
+	Request struct {
+		InvokeTime   int64  `json:"invoke_time"`   // 发起请求的时间戳
+		CostInMs     int64  `json:"cost_in_ms"`    // 请求毫秒级耗时
+		RequestUrl   string `json:"request_url"`   // 请求的URL参数
+		RequestBody  string `json:"request_body"`  // post请求的请求参数
+		ResponseBody string `json:"response_body"` // 接口请求返回参数
+		ClientIp     string `json:"client_ip"`     // 接口请求的客户端ip
+	} `json:"request"`
+}
+
+// GetRid
+// @Description: 根据错误信息中的rid查询请求详情，rid可从*WxError中获取
+// @receiver c
+// @param rid
+// @return *ResRid
+// @return error
+func (c *Context) GetRid(rid string) (*ResRid, error) {
+	var resp ResRid
+	if err := NewHttp(MethodPost, ApiCgiBin.WithPath("openapi/rid/get")).
+		Idempotent(true).
+		SetAccessTokenFrom(c).
+		SetJson(map[string]string{"rid": rid}).
+		BindJson(&resp).
+		Debug(c.debug, c.logger).
+		Do(); err != nil {
+		return nil, c.ErrorWith("get_rid", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.GetRid(rid)
+		}
+		return nil, c.ErrorFrom("get_rid", resp.WxResponse)
+	}
+	return &resp, nil
+}
@@ -1,6 +1,9 @@
 package zwx
 
-import "time"
+import (
+	"github.com/zohu/zwx/utils"
+	"time"
+)
 
 type ResAccessToken struct {
 	WxResponse
@@ -10,8 +13,10 @@ type ResAccessToken struct {
 type TicketType string
 
 const (
-	TicketTypeJs   TicketType = "jsapi"
-	TicketTypeCard TicketType = "wx_card"
+	TicketTypeJs        TicketType = "jsapi"
+	TicketTypeCard      TicketType = "wx_card"
+	TicketTypeWorkCorp  TicketType = "corp"         // 企业微信企业级jsapi_ticket，用于wx.config
+	TicketTypeWorkAgent TicketType = "agent_config" // 企业微信应用级jsapi_ticket，用于wx.agentConfig
 )
 
 type ResTicket struct {
@@ -20,9 +25,29 @@ type ResTicket struct {
 	ExpiresIn int    `json:"expires_in"`
 }
 
+// errcodeNotReady 依赖尚未就绪，如未收到component_verify_ticket、第三方平台token未就绪，跳过本次刷新，不计入重试和熔断
+const errcodeNotReady = -1000
+
+// newTickets 刷新token关联的ticket，token为空时跳过
+func (c *Context) newTickets() {
+	switch c.app.AppType {
+	case TypeWxMpServe:
+		c.newMpTicket(TicketTypeJs)
+		c.newMpTicket(TicketTypeCard)
+	case TypeWxMpSubscribe:
+		c.newMpTicket(TicketTypeJs)
+	case TypeWxWork:
+		c.newWorkTicket(TicketTypeWorkCorp)
+		c.newWorkTicket(TicketTypeWorkAgent)
+	}
+}
+
 // -------------------------------mp-------------------------------
 
-func (c *Context) newMpToken() {
+func (c *Context) newMpToken() int {
+	if c.app.ComponentAppid != "" {
+		return c.newAuthorizerToken()
+	}
 	var resp ResAccessToken
 	if err := NewHttp(MethodGet, ApiCgiBin.WithPath("token")).
 		SetQuery(map[string]string{
@@ -34,14 +59,15 @@ func (c *Context) newMpToken() {
 		Debug(c.debug, c.logger).
 		Do(); err != nil {
 		c.logger.Errorf("%s request access_token failed：%s", c.AppidMain(), err.Error())
-		return
+		return ErrcodeSystemBusy
 	}
 	if resp.Errcode != 0 {
 		c.logger.Errorf("%s request access_token failed：%s", c.AppidMain(), resp.Errmsg)
-		return
+		return resp.Errcode
 	}
 	c.app.AccessToken = resp.AccessToken
-	c.app.ExpireTime = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	c.app.ExpireTime = utils.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return 0
 }
 func (c *Context) newMpTicket(t TicketType) {
 	if c.app.AccessToken == "" {
@@ -73,7 +99,7 @@ func (c *Context) newMpTicket(t TicketType) {
 
 // -------------------------------work-------------------------------
 
-func (c *Context) newWorkToken() {
+func (c *Context) newWorkToken() int {
 	var resp ResAccessToken
 	if err := NewHttp(MethodGet, ApiWorkCgiBin.WithPath("gettoken")).
 		SetQuery(map[string]string{
@@ -84,25 +110,29 @@ func (c *Context) newWorkToken() {
 		Debug(c.debug, c.logger).
 		Do(); err != nil {
 		c.logger.Errorf("%s request work access_token failed：%s", c.AppidMain(), err.Error())
-		return
+		return ErrcodeSystemBusy
 	}
 	if resp.Errcode != 0 {
 		c.logger.Errorf("%s request work access_token failed：%s", c.AppidMain(), resp.Errmsg)
-		return
+		return resp.Errcode
 	}
 	c.app.AccessToken = resp.AccessToken
-	c.app.ExpireTime = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	c.app.ExpireTime = utils.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return 0
 }
-func (c *Context) newWorkTicket() {
+func (c *Context) newWorkTicket(t TicketType) {
 	if c.app.AccessToken == "" {
 		return
 	}
 	var resp ResTicket
-	if err := NewHttp(MethodGet, ApiWorkCgiBin.WithPath("ticket/get")).
-		SetAccessToken(c.app.AccessToken).
-		SetQuery(map[string]string{
-			"type": "agent_config",
-		}).
+	h := NewHttp(MethodGet, ApiWorkCgiBin.WithPath("get_jsapi_ticket"))
+	if t == TicketTypeWorkAgent {
+		h = NewHttp(MethodGet, ApiWorkCgiBin.WithPath("ticket/get")).
+			SetQuery(map[string]string{
+				"type": string(t),
+			})
+	}
+	if err := h.SetAccessToken(c.app.AccessToken).
 		BindJson(&resp).
 		Debug(c.debug, c.logger).
 		Do(); err != nil {
@@ -113,5 +143,111 @@ func (c *Context) newWorkTicket() {
 		c.logger.Errorf("%s request ticket failed：%s", c.app.AccessToken, resp.Errmsg)
 		return
 	}
-	c.app.JsTicket = resp.Ticket
+	switch t {
+	case TicketTypeWorkCorp:
+		c.app.JsTicket = resp.Ticket
+	case TicketTypeWorkAgent:
+		c.app.AgentTicket = resp.Ticket
+	}
+}
+
+// -------------------------------open-------------------------------
+
+// verifyTicketExpire component_verify_ticket每10分钟推送一次，有效期12小时
+const verifyTicketExpire = time.Hour * 12
+
+type ResComponentToken struct {
+	WxResponse
+	ComponentAccessToken string `json:"component_access_token"`
+	ExpiresIn            int    `json:"expires_in"`
+}
+type ResAuthorizerToken struct {
+	WxResponse
+	AuthorizerAccessToken  string `json:"authorizer_access_token"`
+	ExpiresIn              int    `json:"expires_in"`
+	AuthorizerRefreshToken string `json:"authorizer_refresh_token"`
+}
+
+// SetVerifyTicket
+// @Description: 保存开放平台推送的component_verify_ticket，用于获取component_access_token
+// @receiver c
+// @param ticket
+func (c *Context) SetVerifyTicket(ticket string) {
+	c.storage.SetEX(PrefixTicket.Key(c.Appid()), ticket, verifyTicketExpire)
+}
+
+// VerifyTicket
+// @Description: 最近一次推送的component_verify_ticket
+// @receiver c
+// @return string
+func (c *Context) VerifyTicket() string {
+	return c.storage.Get(PrefixTicket.Key(c.Appid()))
+}
+
+func (c *Context) newComponentToken() int {
+	ticket := c.VerifyTicket()
+	if ticket == "" {
+		c.logger.Debugf("%s skip component_access_token: component_verify_ticket not received", c.Appid())
+		return errcodeNotReady
+	}
+	var resp ResComponentToken
+	if err := NewHttp(MethodPost, ApiCgiBin.WithPath("component/api_component_token")).
+		SetJson(map[string]string{
+			"component_appid":         c.Appid(),
+			"component_appsecret":     c.AppSecret(),
+			"component_verify_ticket": ticket,
+		}).
+		BindJson(&resp).
+		Debug(c.debug, c.logger).
+		Do(); err != nil {
+		c.logger.Errorf("%s request component_access_token failed：%s", c.Appid(), err.Error())
+		return ErrcodeSystemBusy
+	}
+	if resp.Errcode != 0 {
+		c.logger.Errorf("%s request component_access_token failed：%s", c.Appid(), resp.Errmsg)
+		return resp.Errcode
+	}
+	c.app.AccessToken = resp.ComponentAccessToken
+	c.app.ExpireTime = utils.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return 0
+}
+
+// newAuthorizerToken 代授权的公众号、小程序通过第三方平台刷新token，AppSecret保存authorizer_refresh_token
+func (c *Context) newAuthorizerToken() int {
+	component, err := LoadApp(c.app.ComponentAppid)
+	if err != nil {
+		c.logger.Errorf("%s request authorizer_access_token failed：%s", c.Appid(), err.Error())
+		return ErrcodeSystemBusy
+	}
+	token := component.AccessToken()
+	if token == "" {
+		c.logger.Debugf("%s skip authorizer_access_token: component %s token not ready", c.Appid(), component.Appid())
+		return errcodeNotReady
+	}
+	var resp ResAuthorizerToken
+	if err = NewHttp(MethodPost, ApiCgiBin.WithPath("component/api_authorizer_token")).
+		SetQuery(map[string]string{
+			"component_access_token": token,
+		}).
+		SetJson(map[string]string{
+			"component_appid":          component.Appid(),
+			"authorizer_appid":         c.Appid(),
+			"authorizer_refresh_token": c.AppSecret(),
+		}).
+		BindJson(&resp).
+		Debug(c.debug, c.logger).
+		Do(); err != nil {
+		c.logger.Errorf("%s request authorizer_access_token failed：%s", c.Appid(), err.Error())
+		return ErrcodeSystemBusy
+	}
+	if resp.Errcode != 0 {
+		c.logger.Errorf("%s request authorizer_access_token failed：%s", c.Appid(), resp.Errmsg)
+		return resp.Errcode
+	}
+	c.app.AccessToken = resp.AuthorizerAccessToken
+	c.app.ExpireTime = utils.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	if resp.AuthorizerRefreshToken != "" {
+		c.app.AppSecret = resp.AuthorizerRefreshToken
+	}
+	return 0
 }
@@ -0,0 +1,35 @@
+package zwx
+
+import (
+	"github.com/zohu/zwx/internal/memstore"
+	"testing"
+)
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Debugf(format string, v ...any) { l.t.Logf(format, v...) }
+func (l testLogger) Infof(format string, v ...any)  { l.t.Logf(format, v...) }
+func (l testLogger) Errorf(format string, v ...any) { l.t.Logf(format, v...) }
+func (l testLogger) Fatalf(format string, v ...any) { l.t.Fatalf(format, v...) }
+
+// setupTest 使用内存存储初始化，按需获取token且不自动刷新，测试中不会请求微信接口
+// 包内测试依赖未导出状态，不能引用internal/zwxtest(循环导入)，其余包统一使用zwxtest.Setup
+func setupTest(t *testing.T) *memstore.Store {
+	t.Helper()
+	s := memstore.New()
+	New(&Options{
+		Storage:            s,
+		Logger:             testLogger{t},
+		LazyToken:          true,
+		DisableAutoRefresh: true,
+	})
+	t.Cleanup(Shutdown)
+	return s
+}
+
+// testApp 视频号不在NewAccessToken中请求token，适合测试托管流程
+func testApp(appid string) App {
+	return App{AppType: TypeWxVideo, Appid: appid, AppSecret: "secret-" + appid}
+}
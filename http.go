@@ -8,6 +8,7 @@ import (
 	"github.com/valyala/fasthttp"
 	"github.com/zohu/zwx/utils"
 	"strings"
+	"time"
 )
 
 type Api string
@@ -44,20 +45,30 @@ const (
 )
 
 type Http struct {
-	c        *fasthttp.Client
-	req      *fasthttp.Request
-	resp     *fasthttp.Response
-	handlers []func(resp *fasthttp.Response)
-	errs     []string
-	debug    bool
-	logger   Logger
+	c          *fasthttp.Client
+	req        *fasthttp.Request
+	resp       *fasthttp.Response
+	handlers   []func(resp *fasthttp.Response)
+	errs       []string
+	err        error
+	debug      bool
+	logger     Logger
+	idempotent bool
+	retry      *RetryPolicy
+	deadline   time.Time
 }
 
 func NewHttp(method Method, uri string) *Http {
+	c := httpClient.Load()
+	if c == nil {
+		c = &fasthttp.Client{}
+	}
 	h := &Http{
-		c:    &fasthttp.Client{},
-		req:  fasthttp.AcquireRequest(),
-		resp: fasthttp.AcquireResponse(),
+		c:          c,
+		req:        fasthttp.AcquireRequest(),
+		resp:       fasthttp.AcquireResponse(),
+		idempotent: method == MethodGet,
+		retry:      retryPolicy.Load(),
 	}
 	h.req.SetRequestURI(uri)
 	h.req.Header.SetMethod(method.String())
@@ -97,6 +108,19 @@ func (h *Http) SetAccessToken(token string) *Http {
 	h.req.URI().QueryArgs().Add("access_token", token)
 	return h
 }
+
+// SetAccessTokenFrom
+// @Description: 使用APP的access_token，APP熔断时不发送请求，Do返回*CircuitOpenError
+// @receiver h
+// @param c
+// @return *Http
+func (h *Http) SetAccessTokenFrom(c *Context) *Http {
+	if err := c.CircuitError(); err != nil {
+		h.err = err
+		return h
+	}
+	return h.SetAccessToken(c.AccessToken())
+}
 func (h *Http) SetQuery(query map[string]string) *Http {
 	for k, v := range query {
 		h.req.URI().QueryArgs().Add(k, fmt.Sprintf("%v", v))
@@ -142,8 +166,24 @@ func (h *Http) Debug(debug bool, logger Logger) *Http {
 func (h *Http) Do() error {
 	defer fasthttp.ReleaseRequest(h.req)
 	defer fasthttp.ReleaseResponse(h.resp)
-	// 发送请求
-	if err := h.c.Do(h.req, h.resp); err != nil {
+	if h.err != nil {
+		return h.err
+	}
+	// 发送请求，失败时按策略退避重试，域名切换和重试共用总耗时上限
+	h.deadline = time.Now().Add(h.retry.MaxElapsed)
+	err := h.send()
+	for attempt := 1; attempt < h.retry.MaxAttempts && h.shouldRetry(err); attempt++ {
+		delay := h.retry.backoff(attempt)
+		if time.Now().Add(delay).After(h.deadline) {
+			break
+		}
+		if h.debug {
+			h.logger.Debugf("retry %s in %s, attempt %d", h.req.URI().Path(), delay, attempt+1)
+		}
+		time.Sleep(delay)
+		err = h.send()
+	}
+	if err != nil {
 		h.errs = append(h.errs, fmt.Sprintf("request error: %v", err))
 	} else {
 		// 序列化返回值
@@ -200,3 +240,34 @@ func (h *Http) Do() error {
 	}
 	return nil
 }
+
+// send 发送请求，微信通用域名连接失败或5xx时切换容灾域名，非幂等请求只在连接未建立时切换
+func (h *Http) send() error {
+	var err error
+	for _, domain := range domains.candidates(string(h.req.URI().Host())) {
+		if err != nil && !time.Now().Before(h.deadline) {
+			return err
+		}
+		h.req.URI().SetHost(domain)
+		h.resp.Reset()
+		if err = h.c.DoDeadline(h.req, h.resp, h.deadline); err != nil {
+			domains.failure(domain, err.Error())
+			// 非幂等请求可能已被处理，只在连接未建立时切换
+			if h.idempotent || isDialError(err) {
+				continue
+			}
+			return err
+		}
+		if code := h.resp.StatusCode(); code >= fasthttp.StatusInternalServerError {
+			err = fmt.Errorf("%s response status %d", domain, code)
+			domains.failure(domain, err.Error())
+			if h.idempotent {
+				continue
+			}
+			return err
+		}
+		domains.success(domain)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package zwx
+
+import (
+	"github.com/zohu/zwx/utils"
+	"sync"
+	"time"
+)
+
+// apiDomains 微信API通用域名及容灾域名，按优先级排列
+var apiDomains = []string{
+	"api.weixin.qq.com",    // 通用域名，就近接入
+	"api2.weixin.qq.com",   // 通用异地容灾域名
+	"sh.api.weixin.qq.com", // 上海域名
+	"sz.api.weixin.qq.com", // 深圳域名
+	"hk.api.weixin.qq.com", // 香港域名
+}
+
+const (
+	// domainFailureThreshold 连续失败多少次后暂时摘除域名
+	domainFailureThreshold = 3
+	// domainCooldown 域名摘除时长
+	domainCooldown = time.Minute
+)
+
+// DomainHealth
+// @Description: API域名健康状态，进程内统计
+type DomainHealth struct {
+	Domain      string    `json:"domain"`
+	Healthy     bool      `json:"healthy"`
+	Failures    int       `json:"failures"` // 连续失败次数
+	LastError   string    `json:"last_error,omitempty"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+	DownUntil   time.Time `json:"down_until,omitempty"`
+}
+
+type domainTracker struct {
+	mu    sync.Mutex
+	stats map[string]*DomainHealth
+}
+
+var domains = &domainTracker{stats: make(map[string]*DomainHealth)}
+
+// DomainHealths
+// @Description: 获取微信API域名的健康状态
+// @return []DomainHealth
+func DomainHealths() []DomainHealth {
+	domains.mu.Lock()
+	defer domains.mu.Unlock()
+	list := make([]DomainHealth, 0, len(apiDomains))
+	for _, d := range apiDomains {
+		list = append(list, *domains.get(d))
+	}
+	return list
+}
+
+// candidates 请求可用的域名，非微信通用域名不做容灾；健康的域名在前，摘除的域名兜底
+func (t *domainTracker) candidates(host string) []string {
+	if !isApiDomain(host) {
+		return []string{host}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	healthy := make([]string, 0, len(apiDomains))
+	var down []string
+	for _, d := range apiDomains {
+		if t.get(d).Healthy {
+			healthy = append(healthy, d)
+		} else {
+			down = append(down, d)
+		}
+	}
+	return append(healthy, down...)
+}
+
+func (t *domainTracker) success(domain string) {
+	if !isApiDomain(domain) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.get(domain)
+	st.Failures = 0
+	st.DownUntil = time.Time{}
+	st.Healthy = true
+}
+
+func (t *domainTracker) failure(domain string, reason string) {
+	if !isApiDomain(domain) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.get(domain)
+	st.Failures++
+	st.LastError = reason
+	st.LastFailure = utils.Now()
+	if st.Failures >= domainFailureThreshold {
+		st.DownUntil = st.LastFailure.Add(domainCooldown)
+		st.Healthy = false
+	}
+}
+
+// get 需持有锁
+func (t *domainTracker) get(domain string) *DomainHealth {
+	st, ok := t.stats[domain]
+	if !ok {
+		st = &DomainHealth{Domain: domain, Healthy: true}
+		t.stats[domain] = st
+	}
+	if !st.Healthy && utils.Now().After(st.DownUntil) {
+		// 冷却结束，重新参与请求，失败一次即再次摘除
+		st.Healthy = true
+		st.Failures = domainFailureThreshold - 1
+	}
+	return st
+}
+
+func isApiDomain(host string) bool {
+	for _, d := range apiDomains {
+		if d == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package zwx
+
+import (
+	"errors"
+	"github.com/bytedance/sonic"
+	"github.com/valyala/fasthttp"
+	"github.com/zohu/zwx/utils"
+	"net"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy
+// @Description: 请求重试策略，非幂等请求(默认POST)只在连接未建立时重试，避免重复发送消息等操作
+type RetryPolicy struct {
+	// 最大尝试次数(包含首次)，默认3，1为不重试
+	MaxAttempts int
+	// 首次重试的等待时间，之后指数增长，默认100毫秒
+	BaseDelay time.Duration
+	// 最大等待时间，默认2秒
+	MaxDelay time.Duration
+	// 可重试的错误码，nil时默认[-1]系统繁忙，空切片[]int{}表示不按错误码重试
+	Errcodes []int
+	// 单次请求的总耗时上限，包含容灾域名切换、重试及等待，默认10秒
+	MaxElapsed time.Duration
+}
+
+// retryPolicy 全局重试策略，New时可被替换，请求中并发读取
+var retryPolicy atomic.Pointer[RetryPolicy]
+
+// httpClient 全局HTTP客户端，为空时每次请求新建
+var httpClient atomic.Pointer[fasthttp.Client]
+
+func init() {
+	retryPolicy.Store(RetryPolicy{}.withDefaults())
+}
+
+// withDefaults 返回填充默认值后的副本，不修改调用方传入的策略
+func (p RetryPolicy) withDefaults() *RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = 100 * time.Millisecond
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = 2 * time.Second
+	}
+	if p.Errcodes == nil {
+		p.Errcodes = []int{ErrcodeSystemBusy}
+	}
+	if p.MaxElapsed == 0 {
+		p.MaxElapsed = 10 * time.Second
+	}
+	return &p
+}
+
+// backoff 指数退避，在[delay/2, delay]之间随机抖动
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + utils.GetProvider().Int64N(half+1))
+}
+
+// Idempotent
+// @Description: 标记请求是否幂等，GET默认幂等，POST默认非幂等
+// @receiver h
+// @param idempotent
+// @return *Http
+func (h *Http) Idempotent(idempotent bool) *Http {
+	h.idempotent = idempotent
+	return h
+}
+
+// Retry
+// @Description: 覆盖本次请求的重试策略，nil时使用全局策略
+// @receiver h
+// @param policy
+// @return *Http
+func (h *Http) Retry(policy *RetryPolicy) *Http {
+	if policy == nil {
+		h.retry = retryPolicy.Load()
+		return h
+	}
+	h.retry = policy.withDefaults()
+	return h
+}
+
+// shouldRetry 判断本次结果是否可重试
+func (h *Http) shouldRetry(err error) bool {
+	if err != nil {
+		return h.idempotent || isDialError(err)
+	}
+	if !h.idempotent || h.resp.StatusCode() != fasthttp.StatusOK {
+		return false
+	}
+	var resp WxResponse
+	if sonic.Unmarshal(h.resp.Body(), &resp) != nil || resp.Errcode == 0 {
+		return false
+	}
+	return slices.Contains(h.retry.Errcodes, resp.Errcode)
+}
+
+// isDialError 连接尚未建立，请求一定没有发出
+func isDialError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, fasthttp.ErrDialTimeout) || errors.Is(err, fasthttp.ErrNoFreeConns)
+}
@@ -0,0 +1,201 @@
+package zwx
+
+import (
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"github.com/zohu/zwx/utils"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	utils.SetProvider(utils.NewSeededProvider(1, time.Unix(1700000000, 0)))
+	t.Cleanup(func() { utils.SetProvider(nil) })
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}.withDefaults()
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{70, time.Second}, // 移位溢出
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempt), func(t *testing.T) {
+			for range 20 {
+				if d := p.backoff(tt.attempt); d < tt.max/2 || d > tt.max {
+					t.Fatalf("backoff = %s, want [%s, %s]", d, tt.max/2, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	setupTest(t)
+	custom := &RetryPolicy{MaxAttempts: 5}
+	p := custom.withDefaults()
+	if custom.BaseDelay != 0 || custom.Errcodes != nil {
+		t.Errorf("caller policy mutated: %+v", custom)
+	}
+	if p.MaxAttempts != 5 || p.BaseDelay != 100*time.Millisecond || len(p.Errcodes) != 1 || p.Errcodes[0] != ErrcodeSystemBusy {
+		t.Errorf("defaults = %+v", p)
+	}
+	if p = (&RetryPolicy{Errcodes: []int{}}).withDefaults(); p.Errcodes == nil || len(p.Errcodes) != 0 {
+		t.Errorf("empty errcodes = %v, want kept empty", p.Errcodes)
+	}
+	h := NewHttp(MethodGet, "http://example.com").Retry(custom).Retry(nil)
+	if h.retry != retryPolicy.Load() {
+		t.Errorf("Retry(nil) = %+v, want global policy", h.retry)
+	}
+}
+
+// testApi 模拟微信API，按Host记录请求次数；down中的域名连接失败，fail5xx中的域名返回502，前busy次请求返回系统繁忙
+type testApi struct {
+	mu      sync.Mutex
+	calls   map[string]int
+	busy    int
+	down    map[string]bool
+	fail5xx map[string]bool
+}
+
+func (a *testApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls[r.Host]++
+	if a.fail5xx[r.Host] {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	if a.busy > 0 {
+		a.busy--
+		_, _ = w.Write([]byte(`{"errcode":-1,"errmsg":"system busy"}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+}
+
+func (a *testApi) total() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for _, c := range a.calls {
+		n += c
+	}
+	return n
+}
+
+// resetDomains 域名健康状态为进程内全局状态，测试前后重置
+func resetDomains(t *testing.T) {
+	domains = &domainTracker{stats: make(map[string]*DomainHealth)}
+	t.Cleanup(func() { domains = &domainTracker{stats: make(map[string]*DomainHealth)} })
+}
+
+func TestDoRetryAndFailover(t *testing.T) {
+	setupTest(t)
+	fast := &RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	tests := []struct {
+		name      string
+		method    Method
+		policy    *RetryPolicy
+		busy      int
+		down      []string
+		fail5xx   []string
+		wantCalls map[string]int
+		wantErr   string
+	}{
+		{name: "get retries system busy", method: MethodGet, policy: fast, busy: 2,
+			wantCalls: map[string]int{"api.weixin.qq.com": 3}},
+		{name: "get gives up after max attempts", method: MethodGet, policy: fast, busy: 5,
+			wantCalls: map[string]int{"api.weixin.qq.com": 3}},
+		{name: "post does not retry system busy", method: MethodPost, policy: fast, busy: 2,
+			wantCalls: map[string]int{"api.weixin.qq.com": 1}},
+		{name: "empty errcodes disable errcode retry", method: MethodGet, busy: 2,
+			policy:    &RetryPolicy{BaseDelay: time.Millisecond, Errcodes: []int{}},
+			wantCalls: map[string]int{"api.weixin.qq.com": 1}},
+		{name: "backoff beyond deadline stops retry", method: MethodGet, busy: 2,
+			policy:    &RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Second, MaxElapsed: 100 * time.Millisecond},
+			wantCalls: map[string]int{"api.weixin.qq.com": 1}},
+		{name: "get fails over on dial error", method: MethodGet, policy: fast, down: []string{"api.weixin.qq.com"},
+			wantCalls: map[string]int{"api2.weixin.qq.com": 1}},
+		{name: "post fails over on dial error", method: MethodPost, policy: fast, down: []string{"api.weixin.qq.com"},
+			wantCalls: map[string]int{"api2.weixin.qq.com": 1}},
+		{name: "get fails over on 5xx", method: MethodGet, policy: fast, fail5xx: []string{"api.weixin.qq.com"},
+			wantCalls: map[string]int{"api.weixin.qq.com": 1, "api2.weixin.qq.com": 1}},
+		{name: "post does not fail over on 5xx", method: MethodPost, policy: &RetryPolicy{MaxAttempts: 1}, fail5xx: []string{"api.weixin.qq.com"},
+			wantCalls: map[string]int{"api.weixin.qq.com": 1}, wantErr: "response status 502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDomains(t)
+			api := &testApi{calls: make(map[string]int), busy: tt.busy, down: make(map[string]bool), fail5xx: make(map[string]bool)}
+			for _, d := range tt.down {
+				api.down[d] = true
+			}
+			for _, d := range tt.fail5xx {
+				api.fail5xx[d] = true
+			}
+			srv := httptest.NewServer(api)
+			t.Cleanup(srv.Close)
+			h := NewHttp(tt.method, "http://api.weixin.qq.com/cgi-bin/test").Retry(tt.policy)
+			h.c.Dial = func(addr string) (net.Conn, error) {
+				host, _, _ := net.SplitHostPort(addr)
+				if api.down[host] {
+					return nil, &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}
+				}
+				return fasthttp.Dial(strings.TrimPrefix(srv.URL, "http://"))
+			}
+			var resp WxResponse
+			err := h.BindJson(&resp).Do()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			want := 0
+			for host, n := range tt.wantCalls {
+				want += n
+				if api.calls[host] != n {
+					t.Errorf("calls[%s] = %d, want %d (all %v)", host, api.calls[host], n, api.calls)
+				}
+			}
+			if got := api.total(); got != want {
+				t.Errorf("total calls = %d, want %d (all %v)", got, want, api.calls)
+			}
+		})
+	}
+}
+
+func TestDomainHealth(t *testing.T) {
+	resetDomains(t)
+	base := time.Unix(1700000000, 0)
+	utils.SetProvider(utils.NewSeededProvider(1, base))
+	t.Cleanup(func() { utils.SetProvider(nil) })
+	for range domainFailureThreshold {
+		domains.failure("api.weixin.qq.com", "dial timeout")
+	}
+	if got := domains.candidates("api.weixin.qq.com"); got[len(got)-1] != "api.weixin.qq.com" {
+		t.Errorf("candidates = %v, want unhealthy domain last", got)
+	}
+	if got := domains.candidates("example.com"); len(got) != 1 || got[0] != "example.com" {
+		t.Errorf("candidates = %v, want no failover for other hosts", got)
+	}
+	utils.SetProvider(utils.NewSeededProvider(1, base.Add(domainCooldown+time.Second)))
+	if got := domains.candidates("api.weixin.qq.com"); got[0] != "api.weixin.qq.com" {
+		t.Errorf("candidates = %v, want domain back after cooldown", got)
+	}
+	// 冷却后再失败一次即摘除
+	domains.failure("api.weixin.qq.com", "dial timeout")
+	if got := DomainHealths()[0]; got.Healthy {
+		t.Errorf("health = %+v, want unhealthy after one more failure", got)
+	}
+}
@@ -0,0 +1,108 @@
+package memstore
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store
+// @Description: 内存存储，实现zwx.Storage，不处理过期，仅用于测试
+type Store struct {
+	mu   sync.Mutex
+	kv   map[string]string
+	sets map[string]map[string]bool
+	hash map[string]map[string]string
+}
+
+// New
+// @Description: 创建内存存储
+// @return *Store
+func New() *Store {
+	return &Store{
+		kv:   make(map[string]string),
+		sets: make(map[string]map[string]bool),
+		hash: make(map[string]map[string]string),
+	}
+}
+
+func (m *Store) Get(key string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.kv[key]
+}
+func (m *Store) Del(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.kv, key)
+	delete(m.sets, key)
+	delete(m.hash, key)
+}
+func (m *Store) SetEX(key, value string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kv[key] = value
+}
+func (m *Store) SetNX(key, value string, _ time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.kv[key]; ok {
+		return false
+	}
+	m.kv[key] = value
+	return true
+}
+func (m *Store) SAdd(key string, members ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sets[key] == nil {
+		m.sets[key] = make(map[string]bool)
+	}
+	for _, v := range members {
+		m.sets[key][v] = true
+	}
+}
+func (m *Store) SRem(key string, members ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range members {
+		delete(m.sets[key], v)
+	}
+}
+func (m *Store) SMembers(key string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var r []string
+	for v := range m.sets[key] {
+		r = append(r, v)
+	}
+	return r
+}
+func (m *Store) HSet(key string, values map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hash[key] == nil {
+		m.hash[key] = make(map[string]string)
+	}
+	for k, v := range values {
+		m.hash[key][k] = v
+	}
+}
+func (m *Store) HGetAll(key string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := make(map[string]string, len(m.hash[key]))
+	for k, v := range m.hash[key] {
+		r[k] = v
+	}
+	return r
+}
+func (m *Store) HIncrBy(key, field string, incr int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hash[key] == nil {
+		m.hash[key] = make(map[string]string)
+	}
+	n, _ := strconv.ParseInt(m.hash[key][field], 10, 64)
+	m.hash[key][field] = strconv.FormatInt(n+incr, 10)
+}
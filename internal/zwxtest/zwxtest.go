@@ -0,0 +1,96 @@
+package zwxtest
+
+import (
+	"crypto/tls"
+	"github.com/valyala/fasthttp"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/internal/memstore"
+	"github.com/zohu/zwx/utils"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Logger
+// @Description: 输出到测试日志的Logger
+type Logger struct {
+	T testing.TB
+}
+
+func (l Logger) Debugf(format string, v ...any) { l.T.Logf(format, v...) }
+func (l Logger) Infof(format string, v ...any)  { l.T.Logf(format, v...) }
+func (l Logger) Errorf(format string, v ...any) { l.T.Logf(format, v...) }
+func (l Logger) Fatalf(format string, v ...any) { l.T.Fatalf(format, v...) }
+
+// Setup
+// @Description: 使用内存存储初始化zwx，按需获取token、不自动刷新、时钟固定为now，测试结束时关闭；
+// 托管apps并直接写入其携带的token与ticket，不请求微信接口
+// @param t
+// @param now
+// @param apps
+// @return *memstore.Store
+func Setup(t testing.TB, now time.Time, apps ...zwx.App) *memstore.Store {
+	t.Helper()
+	return SetupOptions(t, &zwx.Options{Provider: utils.NewSeededProvider(1, now)}, apps...)
+}
+
+// SetupOptions
+// @Description: 同Setup，时钟等其余配置由options指定，如HttpClient使用MockApi
+// @param t
+// @param options
+// @param apps
+// @return *memstore.Store
+func SetupOptions(t testing.TB, options *zwx.Options, apps ...zwx.App) *memstore.Store {
+	t.Helper()
+	s := memstore.New()
+	options.Storage = s
+	options.Logger = Logger{t}
+	options.LazyToken = true
+	options.DisableAutoRefresh = true
+	zwx.New(options)
+	t.Cleanup(func() {
+		zwx.Shutdown()
+		utils.SetProvider(nil)
+	})
+	for _, app := range apps {
+		if err := zwx.CreateApp(app); err != nil {
+			t.Fatal(err)
+		}
+		SeedTokens(s, app)
+	}
+	return s
+}
+
+// SeedTokens
+// @Description: 直接写入app携带的access_token与ticket，有效期为当前时钟起一小时；未携带access_token时不写入
+// @param s
+// @param app
+func SeedTokens(s zwx.Storage, app zwx.App) {
+	if app.AccessToken == "" {
+		return
+	}
+	s.HSet(zwx.PrefixApp.Key(app.Appid), map[string]string{
+		"access_token": app.AccessToken,
+		"js_ticket":    app.JsTicket,
+		"card_ticket":  app.CardTicket,
+		"agent_ticket": app.AgentTicket,
+		"expire_time":  utils.Now().Add(time.Hour).Format(time.RFC3339Nano),
+	})
+}
+
+// MockApi
+// @Description: 模拟微信接口，返回的HTTP客户端将所有请求发往handler，用于zwx.Options.HttpClient
+// @param t
+// @param handler
+// @return *fasthttp.Client
+func MockApi(t testing.TB, handler http.Handler) *fasthttp.Client {
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	addr := srv.Listener.Addr().String()
+	return &fasthttp.Client{
+		Dial:      func(string) (net.Conn, error) { return fasthttp.Dial(addr) },
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+}
@@ -3,9 +3,15 @@ package zwx
 type Prefix string
 
 const (
+	PrefixSchema  Prefix = "wx:0"
 	PrefixAppList Prefix = "wx:1"
 	PrefixApp     Prefix = "wx:2"
 	PrefixRetry   Prefix = "wx:3"
+	PrefixTenant  Prefix = "wx:4"
+	PrefixActive  Prefix = "wx:5"
+	PrefixMember  Prefix = "wx:6"
+	PrefixNotify  Prefix = "wx:7"
+	PrefixTicket  Prefix = "wx:8"
 )
 
 func (p Prefix) Key(val ...string) string {
@@ -16,6 +22,9 @@ func (p Prefix) Key(val ...string) string {
 	return key
 }
 
+// ErrcodeSystemBusy 系统繁忙，网络异常时也视为该错误码
+const ErrcodeSystemBusy = -1
+
 type WxResponse struct {
 	Errcode int    `json:"errcode,omitempty"`
 	Errmsg  string `json:"errmsg,omitempty"`
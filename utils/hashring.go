@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// HashRing
+// @Description: 一致性哈希环，节点增减时只迁移少量key
+type HashRing struct {
+	replicas int
+	keys     []uint32
+	nodes    map[uint32]string
+}
+
+// NewHashRing
+// @Description: 创建哈希环
+// @param replicas 每个节点的虚拟节点数
+// @param nodes
+// @return *HashRing
+func NewHashRing(replicas int, nodes ...string) *HashRing {
+	r := &HashRing{replicas: replicas, nodes: make(map[uint32]string)}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "#" + node))
+			r.keys = append(r.keys, h)
+			r.nodes[h] = node
+		}
+	}
+	sort.Slice(r.keys, func(i, j int) bool {
+		return r.keys[i] < r.keys[j]
+	})
+	return r
+}
+
+// Get
+// @Description: 获取key所属节点，空环返回空字符串
+// @param key
+// @return string
+func (r *HashRing) Get(key string) string {
+	if len(r.keys) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.keys), func(i int) bool {
+		return r.keys[i] >= h
+	})
+	if i == len(r.keys) {
+		i = 0
+	}
+	return r.nodes[r.keys[i]]
+}
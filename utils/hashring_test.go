@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHashRing(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("wx%04d", i)
+	}
+	tests := []struct {
+		name  string
+		nodes []string
+	}{
+		{"single", []string{"a"}},
+		{"three", []string{"a", "b", "c"}},
+		{"order independent", []string{"c", "a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewHashRing(100, tt.nodes...)
+			counts := make(map[string]int)
+			for _, k := range keys {
+				node := r.Get(k)
+				if node != r.Get(k) {
+					t.Fatalf("Get(%s) not stable", k)
+				}
+				counts[node]++
+			}
+			if len(counts) != len(tt.nodes) {
+				t.Fatalf("keys spread over %v, want %d nodes", counts, len(tt.nodes))
+			}
+			// 每个节点至少分到平均值的一半
+			for node, n := range counts {
+				if n < len(keys)/len(tt.nodes)/2 {
+					t.Errorf("node %s owns %d/%d keys", node, n, len(keys))
+				}
+			}
+		})
+	}
+}
+
+func TestHashRingOrderIndependent(t *testing.T) {
+	a, b := NewHashRing(100, "a", "b", "c"), NewHashRing(100, "c", "b", "a")
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprintf("wx%d", i)
+		if a.Get(k) != b.Get(k) {
+			t.Fatalf("Get(%s) = %s vs %s", k, a.Get(k), b.Get(k))
+		}
+	}
+}
+
+func TestHashRingRebalance(t *testing.T) {
+	before, after := NewHashRing(100, "a", "b", "c"), NewHashRing(100, "a", "b", "c", "d")
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("wx%d", i)
+		from, to := before.Get(k), after.Get(k)
+		if from != to {
+			moved++
+			if to != "d" {
+				t.Fatalf("key %s moved %s -> %s, only moves to the new node are expected", k, from, to)
+			}
+		}
+	}
+	// 新增一个节点，约1/4的key迁移
+	if moved == 0 || moved > 400 {
+		t.Errorf("moved %d/1000 keys", moved)
+	}
+}
+
+func TestHashRingEmpty(t *testing.T) {
+	if got := NewHashRing(100).Get("wx1"); got != "" {
+		t.Errorf("empty ring Get = %q, want empty", got)
+	}
+}
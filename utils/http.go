@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"github.com/bytedance/sonic"
+	"net/http"
+)
+
+// WriteJson
+// @Description: 输出JSON响应
+// @param w
+// @param code
+// @param v
+func WriteJson(w http.ResponseWriter, code int, v any) {
+	d, _ := sonic.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(d)
+}
+
+// WriteError
+// @Description: 输出{"error": "..."}
+// @param w
+// @param code
+// @param err
+func WriteError(w http.ResponseWriter, code int, err error) {
+	WriteJson(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	mrand "math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const nonceAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Provider
+// @Description: 随机数与时钟来源，zwx生成的nonce、时间戳、加密随机前缀与退避抖动均由此获取
+type Provider interface {
+	// NonceStr 长度为length的随机字符串，字符集为数字与大小写字母
+	NonceStr(length int) string
+	// Int64N [0, n)之间的随机数
+	Int64N(n int64) int64
+	// Now 当前时间
+	Now() time.Time
+}
+
+var provider atomic.Pointer[Provider]
+
+func init() {
+	SetProvider(SecureProvider{})
+}
+
+// SetProvider
+// @Description: 替换全局Provider，nil时恢复为SecureProvider
+// @param p
+func SetProvider(p Provider) {
+	if p == nil {
+		p = SecureProvider{}
+	}
+	provider.Store(&p)
+}
+
+// GetProvider
+// @Description: 当前全局Provider
+// @return Provider
+func GetProvider() Provider {
+	return *provider.Load()
+}
+
+// NonceStr
+// @Description: 使用全局Provider生成随机字符串
+// @param length
+// @return string
+func NonceStr(length int) string {
+	return GetProvider().NonceStr(length)
+}
+
+// Now
+// @Description: 使用全局Provider获取当前时间
+// @return time.Time
+func Now() time.Time {
+	return GetProvider().Now()
+}
+
+// ReadRandom
+// @Description: 使用全局Provider填充随机字节，Provider实现了io.Reader时直接读取，否则逐字节取Int64N
+// @param b
+// @return error
+func ReadRandom(b []byte) error {
+	p := GetProvider()
+	if r, ok := p.(io.Reader); ok {
+		_, err := io.ReadFull(r, b)
+		return err
+	}
+	for i := range b {
+		b[i] = byte(p.Int64N(256))
+	}
+	return nil
+}
+
+// SecureProvider
+// @Description: 默认实现，随机数来自crypto/rand，时钟为系统时间
+type SecureProvider struct{}
+
+func (SecureProvider) NonceStr(length int) string {
+	return nonceStr(length, SecureProvider{}.Int64N)
+}
+func (SecureProvider) Int64N(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	// 拒绝采样，避免取模偏差
+	limit := ^uint64(0) - ^uint64(0)%uint64(n)
+	var b [8]byte
+	for {
+		_, _ = rand.Read(b[:])
+		if v := binary.BigEndian.Uint64(b[:]); v < limit {
+			return int64(v % uint64(n))
+		}
+	}
+}
+func (SecureProvider) Now() time.Time {
+	return time.Now()
+}
+func (SecureProvider) Read(b []byte) (int, error) {
+	return rand.Read(b)
+}
+
+// SeededProvider
+// @Description: 固定种子的确定性实现，时钟固定不走，仅用于测试中生成可复现的加密报文与签名
+type SeededProvider struct {
+	mu  sync.Mutex
+	r   *mrand.Rand
+	now time.Time
+}
+
+// NewSeededProvider
+// @Description: 创建确定性Provider
+// @param seed 随机种子，相同种子产生相同序列
+// @param now 固定的当前时间
+// @return *SeededProvider
+func NewSeededProvider(seed uint64, now time.Time) *SeededProvider {
+	return &SeededProvider{r: mrand.New(mrand.NewPCG(seed, seed)), now: now}
+}
+
+func (p *SeededProvider) NonceStr(length int) string {
+	return nonceStr(length, p.Int64N)
+}
+func (p *SeededProvider) Int64N(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.r.Int64N(n)
+}
+func (p *SeededProvider) Now() time.Time {
+	return p.now
+}
+
+func nonceStr(length int, intn func(int64) int64) string {
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = nonceAlphabet[intn(int64(len(nonceAlphabet)))]
+	}
+	return string(result)
+}
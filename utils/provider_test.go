@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var fixedNow = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestSeededProvider(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b uint64
+		same bool
+	}{
+		{"same seed", 1, 1, true},
+		{"different seed", 1, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pa, pb := NewSeededProvider(tt.a, fixedNow), NewSeededProvider(tt.b, fixedNow)
+			na, nb := pa.NonceStr(32), pb.NonceStr(32)
+			if (na == nb) != tt.same {
+				t.Errorf("NonceStr %q vs %q, want same=%v", na, nb, tt.same)
+			}
+			if !pa.Now().Equal(fixedNow) {
+				t.Errorf("Now = %s, want %s", pa.Now(), fixedNow)
+			}
+		})
+	}
+}
+
+func TestProviderGolden(t *testing.T) {
+	SetProvider(NewSeededProvider(42, fixedNow))
+	t.Cleanup(func() { SetProvider(nil) })
+	b := make([]byte, 16)
+	if err := ReadRandom(b); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "nonce: %s\n", RandomStr(16))
+	_, _ = fmt.Fprintf(&buf, "int: %s\n", RandomIntStr(6))
+	_, _ = fmt.Fprintf(&buf, "bytes: %s\n", hex.EncodeToString(b))
+	_, _ = fmt.Fprintf(&buf, "now: %s\n", Now().Format(time.RFC3339))
+	golden(t, "provider", buf.Bytes())
+}
+
+func TestSecureProvider(t *testing.T) {
+	p := SecureProvider{}
+	for _, n := range []int64{-1, 0, 1, 7, 1 << 40} {
+		v := p.Int64N(n)
+		if n <= 0 && v != 0 || n > 0 && (v < 0 || v >= n) {
+			t.Errorf("Int64N(%d) = %d out of range", n, v)
+		}
+	}
+	if s := p.NonceStr(20); len(s) != 20 {
+		t.Errorf("NonceStr length = %d, want 20", len(s))
+	}
+}
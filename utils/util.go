@@ -8,10 +8,9 @@ import (
 	"github.com/bytedance/sonic"
 	"io"
 	"math"
-	"math/rand"
 	"reflect"
 	"sort"
-	"time"
+	"strings"
 )
 
 // RandomIntStr
@@ -19,22 +18,15 @@ import (
 // @receiver o
 // @return string
 func RandomIntStr(len int) string {
-	return fmt.Sprintf("%0*d", len, rand.Intn(int(math.Pow10(len))))
+	return fmt.Sprintf("%0*d", len, GetProvider().Int64N(int64(math.Pow10(len))))
 }
 
 // RandomStr
-// @Description: 随机生成字符串
+// @Description: 随机生成字符串，由全局Provider生成
 // @param length
 // @return string
 func RandomStr(length int) string {
-	str := "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	bytes := []byte(str)
-	var result []byte
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for i := 0; i < length; i++ {
-		result = append(result, bytes[r.Intn(len(bytes))])
-	}
-	return string(result)
+	return NonceStr(length)
 }
 
 // Signature
@@ -77,3 +69,40 @@ func FirstTruth[T any](args ...T) T {
 	}
 	return args[0]
 }
+
+// NormalizeUrl
+// @Description: 去掉URL中#及其后面部分
+// @param pageUrl
+// @return string
+func NormalizeUrl(pageUrl string) string {
+	u, _, _ := strings.Cut(pageUrl, "#")
+	return u
+}
+
+// JsapiSignature
+// @Description: JS-SDK权限验证签名，url中#及其后面部分会被去掉
+// @param ticket jsapi_ticket
+// @param nonceStr
+// @param timestamp
+// @param url
+// @return string
+func JsapiSignature(ticket, nonceStr, timestamp, url string) string {
+	url = NormalizeUrl(url)
+	h := sha1.New()
+	_, _ = fmt.Fprintf(h, "jsapi_ticket=%s&noncestr=%s&timestamp=%s&url=%s", ticket, nonceStr, timestamp, url)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Mask
+// @Description: 密钥脱敏，保留首尾4位
+// @param s
+// @return string
+func Mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	if len(s) <= 8 {
+		return "****"
+	}
+	return s[:4] + "****" + s[len(s)-4:]
+}
@@ -0,0 +1,55 @@
+package utils
+
+import "testing"
+
+func TestJsapiSignature(t *testing.T) {
+	// 官方文档示例
+	const ticket = "sM4AOVdWfPE4DxkXGEs8VMCPGGVi4C3VM0P37wVUCFvkVAy_90u5h9nbSlYy3-Sl-HhTdfl2fzFy1AOcHKP7qg"
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"plain", "http://mp.weixin.qq.com?params=value", "0f9de62fce790f9a083d5c99e95740ceb90c27ed"},
+		{"fragment", "http://mp.weixin.qq.com?params=value#wechat_redirect", "0f9de62fce790f9a083d5c99e95740ceb90c27ed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JsapiSignature(ticket, "Wm3WZYTPz0wzccnW", "1414587457", tt.url); got != tt.want {
+				t.Errorf("JsapiSignature = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeUrl(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://a.com/p?x=1", "https://a.com/p?x=1"},
+		{"https://a.com/p?x=1#top", "https://a.com/p?x=1"},
+		{"https://a.com/#/route?x=1", "https://a.com/"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeUrl(tt.in); got != tt.want {
+			t.Errorf("NormalizeUrl(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"short", "****"},
+		{"12345678", "****"},
+		{"123456789", "1234****6789"},
+	}
+	for _, tt := range tests {
+		if got := Mask(tt.in); got != tt.want {
+			t.Errorf("Mask(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/bytedance/sonic"
 	"github.com/zohu/zwx/utils"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +16,19 @@ type Wx struct {
 	logger             Logger
 	storage            Storage
 	accessTokenRefresh time.Duration
+	tenantAudit        TenantAuditFunc
+	breakerThreshold   int
+	breakerCooldown    time.Duration
+	lazyToken          bool
+	activeWindow       time.Duration
+	maxActiveApps      int
+	activeMarks        sync.Map
+	sharded            bool
+	instanceID         string
+	heartbeatInterval  time.Duration
+	stop               chan struct{}
+	stopOnce           sync.Once
+	refreshedAt        atomic.Int64
 }
 
 var wx *Wx
@@ -24,23 +38,92 @@ var wx *Wx
 // @param options
 func New(options *Options) {
 	options.Validate()
+	if options.Provider != nil {
+		utils.SetProvider(options.Provider)
+	}
 	wx = &Wx{
 		debug:              options.Debug,
 		logger:             &logger{debug: options.Debug, Logger: options.Logger},
 		storage:            &storage{prefix: options.StoragePrefix, s: options.Storage},
 		accessTokenRefresh: options.AccessTokenRefresh,
+		tenantAudit:        options.TenantAudit,
+		breakerThreshold:   options.BreakerThreshold,
+		breakerCooldown:    options.BreakerCooldown,
+		lazyToken:          options.LazyToken,
+		activeWindow:       options.ActiveWindow,
+		maxActiveApps:      options.MaxActiveApps,
+		sharded:            options.ShardedRefresh && !options.DisableAutoRefresh,
+		instanceID:         utils.FirstTruth(options.InstanceID, defaultInstanceID()),
+		heartbeatInterval:  options.HeartbeatInterval,
+		stop:               make(chan struct{}),
+	}
+	httpClient.Store(options.HttpClient)
+	if options.RetryPolicy != nil {
+		retryPolicy.Store(options.RetryPolicy.withDefaults())
+	} else {
+		retryPolicy.Store(RetryPolicy{}.withDefaults())
 	}
+	wx.refreshedAt.Store(utils.Now().Unix())
+	wx.checkSchema(options.AutoMigrate, options.DisableMigrate)
 	if options.AlwaysCleanBeforeStart {
 		for _, appid := range Appids() {
 			DeleteApp(appid)
 		}
-	} else {
+	}
+	if options.AppConfigFile != "" {
+		wx.reconcileConfig(options.AppConfigFile, options.AppConfigDryRun, options.AppConfigPrune)
+	}
+	if options.DisableAutoRefresh {
+		wx.logger.Infof("init zwx success")
+		return
+	}
+	if wx.sharded {
+		wx.heartbeat()
+		go wx.heartbeatLoop()
+	}
+	if !options.AlwaysCleanBeforeStart {
 		go wx.refreshAccessTokenMember()
 	}
 	go wx.refreshAccessToken()
 	wx.logger.Infof("init zwx success")
 }
 
+func (wx *Wx) checkSchema(autoMigrate, disableMigrate bool) {
+	v, latest := SchemaVersion(), LatestSchemaVersion()
+	pending := v < latest || len(pendingUserMigrations()) > 0
+	empty := len(wx.storage.SMembers(PrefixAppList.Key())) == 0
+	switch {
+	case v > latest:
+		wx.logger.Fatalf("storage schema v%d is newer than supported v%d, please upgrade zwx", v, latest)
+	case pending && disableMigrate:
+		if !empty {
+			wx.logger.Errorf("storage schema v%d is older than v%d or has pending migrations, run migration before use", v, latest)
+		}
+	case pending && (autoMigrate || empty):
+		if report, err := Migrate(false); err != nil {
+			wx.logger.Errorf("migrate storage schema error: %v", err)
+		} else {
+			wx.logger.Debugf("%s", report)
+		}
+	case pending:
+		wx.logger.Errorf("storage schema v%d is older than v%d or has pending migrations, run migration before use", v, latest)
+	}
+}
+
+func (wx *Wx) reconcileConfig(path string, dryRun, prune bool) {
+	cfg, err := LoadAppConfig(path)
+	if err != nil {
+		wx.logger.Fatalf("load app config error: %v", err)
+		return
+	}
+	cfg.Prune = prune
+	plan, err := Reconcile(cfg, dryRun)
+	wx.logger.Infof("%s", plan)
+	if err != nil {
+		wx.logger.Errorf("reconcile app config error: %v", err)
+	}
+}
+
 func (wx *Wx) refreshAccessToken() {
 	defer func() {
 		if r := recover(); r != nil {
@@ -48,13 +131,30 @@ func (wx *Wx) refreshAccessToken() {
 			wx.refreshAccessToken()
 		}
 	}()
-	for range time.NewTicker(wx.accessTokenRefresh).C {
-		wx.refreshAccessTokenMember()
+	ticker := time.NewTicker(wx.accessTokenRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-wx.stop:
+			return
+		case <-ticker.C:
+			wx.refreshAccessTokenMember()
+		}
 	}
 }
 func (wx *Wx) refreshAccessTokenMember() {
 	appids := wx.storage.SMembers(PrefixAppList.Key())
+	// 先按分片过滤，每个实例只淘汰自己负责的APP
+	if wx.sharded {
+		appids = wx.ownedAppids(appids)
+	}
+	if wx.lazyToken {
+		appids = wx.activeAppids(appids)
+	}
 	for _, appid := range appids {
+		if wx.sharded && !wx.claimRefresh(appid) {
+			continue
+		}
 		if c, err := LoadApp(appid); err != nil {
 			wx.logger.Errorf("load app %s error: %v", appid, err)
 		} else {
@@ -62,6 +162,7 @@ func (wx *Wx) refreshAccessTokenMember() {
 			c.NewAccessToken()
 		}
 	}
+	wx.refreshedAt.Store(utils.Now().Unix())
 	wx.logger.Debugf("wx token refreshed")
 }
 
@@ -77,6 +178,12 @@ func LoadApp(appid string) (*Context, error) {
 	if m := wx.storage.HGetAll(PrefixApp.Key(appid)); len(m) == 0 {
 		return nil, fmt.Errorf("appid %s not found", appid)
 	} else {
+		// 空值视为字段不存在，Storage不支持HDel，迁移清除的字段以空值保留，如time字段解析空串会中断反序列化
+		for k, v := range m {
+			if v == "" {
+				delete(m, k)
+			}
+		}
 		app := new(App)
 		d, _ := sonic.Marshal(m)
 		_ = sonic.Unmarshal(d, app)
@@ -85,7 +192,7 @@ func LoadApp(appid string) (*Context, error) {
 }
 
 // CreateApp
-// @Description: 创建并托管APP实例
+// @Description: 创建并托管APP实例，携带未过期的AccessToken时(如第三方平台授权返回的authorizer_access_token)直接使用
 // @param ctx
 // @param app
 // @return error
@@ -93,29 +200,68 @@ func CreateApp(app App) error {
 	if err := utils.Validate(app); err != nil {
 		return fmt.Errorf("create app %s error: %v", app.Appid, err)
 	}
+	token, expireTime := app.AccessToken, app.ExpireTime
 	wxl.Lock()
 	app.Retry = "0"
-	app.ExpireTime = time.Now()
+	app.AccessToken = ""
+	app.ExpireTime = utils.Now()
 	wx.storage.SAdd(PrefixAppList.Key(), app.Appid)
 	wx.storage.HSet(PrefixApp.Key(app.Appid), utils.StructToMap(app))
 	wxl.Unlock()
 	if c, err := LoadApp(app.Appid); err != nil {
 		return fmt.Errorf("create app %s error: %v", app.Appid, err)
-	} else {
+	} else if token != "" && expireTime.After(utils.Now()) {
+		c.SetAccessToken(token, expireTime)
+	} else if !wx.lazyToken {
 		c.NewAccessToken()
 	}
 	wx.logger.Debugf("create app %s success", app.Appid)
 	return nil
 }
 
+// UpdateApp
+// @Description: 更新已托管APP的配置，并立即刷新token；携带未过期的AccessToken时直接使用，不再刷新
+// @param app
+// @return error
+func UpdateApp(app App) error {
+	if err := utils.Validate(app); err != nil {
+		return fmt.Errorf("update app %s error: %v", app.Appid, err)
+	}
+	if _, err := LoadApp(app.Appid); err != nil {
+		return fmt.Errorf("update app %s error: %v", app.Appid, err)
+	}
+	m := utils.StructToMap(declaredApp(app))
+	fields := make(map[string]string, len(appConfigFields))
+	for _, k := range appConfigFields {
+		fields[k] = m[k]
+	}
+	wxl.Lock()
+	wx.storage.HSet(PrefixApp.Key(app.Appid), fields)
+	wxl.Unlock()
+	if c, err := LoadApp(app.Appid); err != nil {
+		return fmt.Errorf("update app %s error: %v", app.Appid, err)
+	} else if app.AccessToken != "" && app.ExpireTime.After(utils.Now()) {
+		c.SetAccessToken(app.AccessToken, app.ExpireTime)
+	} else if wx.lazyToken {
+		c.evictToken()
+	} else {
+		c.NewAccessToken()
+	}
+	wx.logger.Debugf("update app %s success", app.Appid)
+	return nil
+}
+
 // DeleteApp
-// @Description: 停止托管APP实例
+// @Description: 停止托管APP实例，同时移出所属租户的APP列表
 // @param ctx
 // @param appid
 func DeleteApp(appid string) {
 	wxl.Lock()
 	defer wxl.Unlock()
 	wx.logger.Debugf("delete app: %s", appid)
+	if tenant := wx.storage.HGetAll(PrefixApp.Key(appid))["tenant"]; tenant != "" {
+		wx.storage.SRem(PrefixTenant.Key(tenant, "apps"), appid)
+	}
 	wx.storage.SRem(PrefixAppList.Key(), appid)
 	wx.storage.Del(PrefixApp.Key(appid))
 }
@@ -0,0 +1,317 @@
+package zwx
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/bytedance/sonic"
+	"github.com/zohu/zwx/utils"
+	"golang.org/x/crypto/pbkdf2"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ImportMode string
+
+const (
+	ImportModeSkip      ImportMode = "skip"      // 已存在的APP跳过
+	ImportModeOverwrite ImportMode = "overwrite" // 已存在的APP覆盖
+	ImportModeFail      ImportMode = "fail"      // 存在冲突时不导入任何APP
+)
+
+const backupVersion = 1
+
+var (
+	appSecretFields = []string{"app_secret", "token", "encoding_aes_key"}
+	appTokenFields  = []string{"access_token", "js_ticket", "card_ticket", "agent_ticket", "expire_time", "refresh_time", "retry", "breaker", "auth_failures", "auth_errcode", "breaker_opened_at"}
+)
+
+type backupOptions struct {
+	secrets    bool
+	tokens     bool
+	passphrase string
+}
+
+type BackupOption func(o *backupOptions)
+
+// WithSecrets
+// @Description: 是否包含secret、token、EncodingAesKey，默认包含
+func WithSecrets(include bool) BackupOption {
+	return func(o *backupOptions) {
+		o.secrets = include
+	}
+}
+
+// WithTokens
+// @Description: 是否包含access_token、ticket等在线凭证，默认不包含，导入后重新获取
+func WithTokens(include bool) BackupOption {
+	return func(o *backupOptions) {
+		o.tokens = include
+	}
+}
+
+// WithPassphrase
+// @Description: 使用口令加密/解密备份，AES-256-GCM
+func WithPassphrase(passphrase string) BackupOption {
+	return func(o *backupOptions) {
+		o.passphrase = passphrase
+	}
+}
+
+type backupFile struct {
+	Version   int                 `json:"version"`
+	Schema    int                 `json:"schema"`
+	CreatedAt time.Time           `json:"created_at"`
+	Encrypted bool                `json:"encrypted"`
+	Salt      []byte              `json:"salt,omitempty"`
+	Nonce     []byte              `json:"nonce,omitempty"`
+	Data      []byte              `json:"data,omitempty"`
+	Apps      []map[string]string `json:"apps,omitempty"`
+}
+
+// ExportApps
+// @Description: 导出全部已托管APP
+// @param w
+// @param opts
+// @return error
+func ExportApps(w io.Writer, opts ...BackupOption) error {
+	o := newBackupOptions(opts)
+	appids := Appids()
+	sort.Strings(appids)
+	apps := make([]map[string]string, 0, len(appids))
+	for _, appid := range appids {
+		c, err := LoadApp(appid)
+		if err != nil {
+			return fmt.Errorf("export app %s error: %v", appid, err)
+		}
+		m := utils.StructToMap(c.Info())
+		if !o.secrets {
+			deleteFields(m, appSecretFields)
+		}
+		if !o.tokens {
+			deleteFields(m, appTokenFields)
+		}
+		apps = append(apps, m)
+	}
+	file := &backupFile{Version: backupVersion, Schema: SchemaVersion(), CreatedAt: utils.Now(), Apps: apps}
+	if o.passphrase != "" {
+		if err := file.encrypt(o.passphrase); err != nil {
+			return fmt.Errorf("export apps error: %v", err)
+		}
+	}
+	d, err := sonic.ConfigStd.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("export apps error: %v", err)
+	}
+	if _, err = w.Write(d); err != nil {
+		return fmt.Errorf("export apps error: %v", err)
+	}
+	wx.logger.Debugf("export %d apps", len(apps))
+	return nil
+}
+
+// ImportReport
+// @Description: 导入结果
+type ImportReport struct {
+	Created     []string          `json:"created"`
+	Overwritten []string          `json:"overwritten"`
+	Skipped     []string          `json:"skipped"`
+	Failed      map[string]string `json:"failed,omitempty"`
+}
+
+func (r *ImportReport) String() string {
+	s := fmt.Sprintf("zwx import: %d created, %d overwritten, %d skipped, %d failed",
+		len(r.Created), len(r.Overwritten), len(r.Skipped), len(r.Failed))
+	appids := make([]string, 0, len(r.Failed))
+	for appid := range r.Failed {
+		appids = append(appids, appid)
+	}
+	sort.Strings(appids)
+	for _, appid := range appids {
+		s += fmt.Sprintf("\n  ! %s %s", appid, r.Failed[appid])
+	}
+	return s
+}
+
+// ImportApps
+// @Description: 从备份导入APP，备份中缺失的字段保留现有值；未包含在线凭证时导入后立即刷新token
+// @param r
+// @param mode 冲突策略
+// @param opts 加密的备份需要WithPassphrase
+// @return *ImportReport
+// @return error
+func ImportApps(r io.Reader, mode ImportMode, opts ...BackupOption) (*ImportReport, error) {
+	switch mode {
+	case ImportModeSkip, ImportModeOverwrite, ImportModeFail:
+	default:
+		return nil, fmt.Errorf("import apps error: unknown mode %q", mode)
+	}
+	o := newBackupOptions(opts)
+	d, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("import apps error: %v", err)
+	}
+	file := new(backupFile)
+	if err = sonic.Unmarshal(d, file); err != nil {
+		return nil, fmt.Errorf("import apps error: %v", err)
+	}
+	if file.Version != backupVersion {
+		return nil, fmt.Errorf("import apps error: unsupported backup version %d", file.Version)
+	}
+	// 旧版本的备份导入时按缺失字段处理，新版本的备份可能包含无法识别的结构
+	if file.Schema > LatestSchemaVersion() {
+		return nil, fmt.Errorf("import apps error: backup schema v%d is newer than supported v%d", file.Schema, LatestSchemaVersion())
+	}
+	if file.Encrypted {
+		if err = file.decrypt(o.passphrase); err != nil {
+			return nil, fmt.Errorf("import apps error: %v", err)
+		}
+	}
+	existed := make(map[string]bool)
+	for _, appid := range Appids() {
+		existed[appid] = true
+	}
+	if mode == ImportModeFail {
+		var conflicts []string
+		for _, m := range file.Apps {
+			if existed[m["appid"]] {
+				conflicts = append(conflicts, m["appid"])
+			}
+		}
+		if len(conflicts) > 0 {
+			return nil, fmt.Errorf("import apps error: apps %s already exist", strings.Join(conflicts, ","))
+		}
+	}
+	report := &ImportReport{Failed: make(map[string]string)}
+	for _, m := range file.Apps {
+		appid := m["appid"]
+		if existed[appid] && mode == ImportModeSkip {
+			report.Skipped = append(report.Skipped, appid)
+			continue
+		}
+		if err = importApp(m, existed[appid]); err != nil {
+			report.Failed[appid] = err.Error()
+			continue
+		}
+		if existed[appid] {
+			report.Overwritten = append(report.Overwritten, appid)
+		} else {
+			report.Created = append(report.Created, appid)
+		}
+	}
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf("import apps error: %d apps failed", len(report.Failed))
+	}
+	return report, nil
+}
+
+func importApp(m map[string]string, existed bool) error {
+	merged := make(map[string]string)
+	if existed {
+		wxl.Lock()
+		merged = wx.storage.HGetAll(PrefixApp.Key(m["appid"]))
+		wxl.Unlock()
+	}
+	oldTenant := merged["tenant"]
+	for k, v := range m {
+		merged[k] = v
+	}
+	app := new(App)
+	d, _ := sonic.Marshal(merged)
+	if err := sonic.Unmarshal(d, app); err != nil {
+		return err
+	}
+	if err := utils.Validate(*app); err != nil {
+		return err
+	}
+	_, withToken := m["access_token"]
+	if !withToken {
+		app.Retry = "0"
+		app.ExpireTime = utils.Now()
+	}
+	wxl.Lock()
+	wx.storage.SAdd(PrefixAppList.Key(), app.Appid)
+	wx.storage.HSet(PrefixApp.Key(app.Appid), utils.StructToMap(app))
+	wxl.Unlock()
+	// 同步租户的APP列表
+	if oldTenant != "" && oldTenant != app.Tenant {
+		wx.storage.SRem(PrefixTenant.Key(oldTenant, "apps"), app.Appid)
+	}
+	if app.Tenant != "" {
+		wx.storage.SAdd(PrefixTenant.Key(), app.Tenant)
+		wx.storage.SAdd(PrefixTenant.Key(app.Tenant, "apps"), app.Appid)
+	}
+	if !withToken || app.ExpireTime.Before(utils.Now()) {
+		c, err := LoadApp(app.Appid)
+		if err != nil {
+			return err
+		}
+		c.NewAccessToken()
+	}
+	return nil
+}
+
+func (f *backupFile) encrypt(passphrase string) error {
+	plain, err := sonic.Marshal(f.Apps)
+	if err != nil {
+		return err
+	}
+	f.Salt = make([]byte, 16)
+	if err = utils.ReadRandom(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := backupCipher(passphrase, f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if err = utils.ReadRandom(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, plain, nil)
+	f.Encrypted = true
+	f.Apps = nil
+	return nil
+}
+
+func (f *backupFile) decrypt(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("backup is encrypted, passphrase required")
+	}
+	gcm, err := backupCipher(passphrase, f.Salt)
+	if err != nil {
+		return err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return errors.New("wrong passphrase or corrupted backup")
+	}
+	return sonic.Unmarshal(plain, &f.Apps)
+}
+
+func backupCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(passphrase), salt, 100000, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newBackupOptions(opts []BackupOption) *backupOptions {
+	o := &backupOptions{secrets: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func deleteFields(m map[string]string, fields []string) {
+	for _, k := range fields {
+		delete(m, k)
+	}
+}
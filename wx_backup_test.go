@@ -0,0 +1,166 @@
+package zwx
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBackupEncryptDecrypt(t *testing.T) {
+	apps := []map[string]string{{"appid": "wx1", "app_secret": "s1"}, {"appid": "wx2", "app_secret": "s2"}}
+	tests := []struct {
+		name    string
+		decrypt string
+		wantErr string
+	}{
+		{"round trip", "pass", ""},
+		{"wrong passphrase", "other", "wrong passphrase"},
+		{"missing passphrase", "", "passphrase required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &backupFile{Version: backupVersion, Apps: apps}
+			if err := f.encrypt("pass"); err != nil {
+				t.Fatal(err)
+			}
+			if !f.Encrypted || f.Apps != nil || len(f.Data) == 0 {
+				t.Fatalf("encrypted file = %+v", f)
+			}
+			err := f.decrypt(tt.decrypt)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.Apps, apps) {
+				t.Errorf("apps = %v, want %v", f.Apps, apps)
+			}
+		})
+	}
+}
+
+func TestExportImportApps(t *testing.T) {
+	setupTest(t)
+	for _, appid := range []string{"wx1", "wx2"} {
+		if err := CreateApp(testApp(appid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := ExportApps(&buf, WithPassphrase("pass")); err != nil {
+		t.Fatal(err)
+	}
+	backup := buf.Bytes()
+	if bytes.Contains(backup, []byte("secret-wx1")) {
+		t.Fatal("encrypted backup leaks secrets")
+	}
+
+	tests := []struct {
+		name    string
+		mode    ImportMode
+		opts    []BackupOption
+		setup   func()
+		want    ImportReport
+		wantErr string
+	}{
+		{
+			name:  "restore into empty storage",
+			mode:  ImportModeSkip,
+			opts:  []BackupOption{WithPassphrase("pass")},
+			setup: func() { DeleteApp("wx1"); DeleteApp("wx2") },
+			want:  ImportReport{Created: []string{"wx1", "wx2"}},
+		},
+		{
+			name: "skip existing",
+			mode: ImportModeSkip,
+			opts: []BackupOption{WithPassphrase("pass")},
+			want: ImportReport{Skipped: []string{"wx1", "wx2"}},
+		},
+		{
+			name: "overwrite existing",
+			mode: ImportModeOverwrite,
+			opts: []BackupOption{WithPassphrase("pass")},
+			want: ImportReport{Overwritten: []string{"wx1", "wx2"}},
+		},
+		{
+			name:    "fail on conflict",
+			mode:    ImportModeFail,
+			opts:    []BackupOption{WithPassphrase("pass")},
+			wantErr: "apps wx1,wx2 already exist",
+		},
+		{
+			name:    "unknown mode",
+			mode:    "merge",
+			wantErr: `unknown mode "merge"`,
+		},
+		{
+			name:    "missing passphrase",
+			mode:    ImportModeSkip,
+			wantErr: "passphrase required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			report, err := ImportApps(bytes.NewReader(backup), tt.mode, tt.opts...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Created, tt.want.Created) ||
+				!reflect.DeepEqual(report.Skipped, tt.want.Skipped) ||
+				!reflect.DeepEqual(report.Overwritten, tt.want.Overwritten) {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+		})
+	}
+	c, err := LoadApp("wx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AppSecret() != "secret-wx1" {
+		t.Errorf("restored secret = %q", c.AppSecret())
+	}
+}
+
+func TestImportAppsNewerSchema(t *testing.T) {
+	setupTest(t)
+	data := `{"version":1,"schema":99,"apps":[]}`
+	if _, err := ImportApps(strings.NewReader(data), ImportModeSkip); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("err = %v, want newer schema error", err)
+	}
+}
+
+func TestImportAppsTenant(t *testing.T) {
+	setupTest(t)
+	data := `{"version":1,"schema":1,"apps":[{"app_type":"8","appid":"wx1","app_secret":"s","tenant":"t1"}]}`
+	if _, err := ImportApps(strings.NewReader(data), ImportModeOverwrite); err != nil {
+		t.Fatal(err)
+	}
+	apps := wx.storage.SMembers(PrefixTenant.Key("t1", "apps"))
+	sort.Strings(apps)
+	if !reflect.DeepEqual(apps, []string{"wx1"}) {
+		t.Errorf("tenant apps = %v, want [wx1]", apps)
+	}
+}
+
+func TestImportReportString(t *testing.T) {
+	r := &ImportReport{Created: []string{"a"}, Failed: map[string]string{"z": "bad", "b": "worse"}}
+	want := "zwx import: 1 created, 0 overwritten, 0 skipped, 2 failed\n  ! b worse\n  ! z bad"
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,341 @@
+package zwx
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/bytedance/sonic"
+	"github.com/zohu/zwx/utils"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AppConfig
+// @Description: 声明式APP配置文件，支持yaml/json/toml，支持${ENV}、${ENV:-default}环境变量插值
+type AppConfig struct {
+	Apps []App `json:"apps"`
+	// 删除配置中不存在的APP，不从配置文件读取，默认false仅创建、更新
+	Prune bool `json:"-"`
+}
+
+type ConfigFormat string
+
+const (
+	ConfigFormatYaml ConfigFormat = "yaml"
+	ConfigFormatJson ConfigFormat = "json"
+	ConfigFormatToml ConfigFormat = "toml"
+)
+
+// appConfigFields 配置文件可声明的字段，其余均为内部维护字段
+var appConfigFields = []string{"app_type", "app_secret", "main_appid", "agent_id", "component_appid", "token", "encoding_aes_key", "notify_uri"}
+
+// LoadAppConfig
+// @Description: 读取配置文件，根据扩展名识别格式
+// @param path
+// @return *AppConfig
+// @return error
+func LoadAppConfig(path string) (*AppConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read app config %s error: %v", path, err)
+	}
+	format, err := configFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAppConfig(data, format)
+}
+
+// SaveAppConfig
+// @Description: 按扩展名识别格式写入配置文件，只包含可声明的字段，可再由LoadAppConfig读取
+// @param path
+// @param apps
+// @return error
+func SaveAppConfig(path string, apps []App) error {
+	format, err := configFormat(path)
+	if err != nil {
+		return err
+	}
+	d, err := MarshalAppConfig(apps, format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, d, 0600)
+}
+
+// MarshalAppConfig
+// @Description: 将APP序列化为配置文件内容，丢弃内部维护字段与空值
+// @param apps
+// @param format
+// @return []byte
+// @return error
+func MarshalAppConfig(apps []App, format ConfigFormat) ([]byte, error) {
+	list := make([]map[string]string, 0, len(apps))
+	for _, app := range apps {
+		m := utils.StructToMap(app)
+		item := map[string]string{"appid": app.Appid}
+		for _, k := range appConfigFields {
+			if m[k] != "" {
+				item[k] = m[k]
+			}
+		}
+		list = append(list, item)
+	}
+	cfg := map[string]any{"apps": list}
+	switch format {
+	case ConfigFormatYaml:
+		return yaml.Marshal(cfg)
+	case ConfigFormatJson:
+		return sonic.ConfigStd.MarshalIndent(cfg, "", "  ")
+	case ConfigFormatToml:
+		buf := new(bytes.Buffer)
+		err := toml.NewEncoder(buf).Encode(cfg)
+		return buf.Bytes(), err
+	}
+	return nil, fmt.Errorf("unsupported format %s", format)
+}
+
+func configFormat(path string) (ConfigFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ConfigFormatYaml, nil
+	case ".json":
+		return ConfigFormatJson, nil
+	case ".toml":
+		return ConfigFormatToml, nil
+	}
+	return "", fmt.Errorf("unsupported app config format: %s", path)
+}
+
+// ParseAppConfig
+// @Description: 解析配置内容，先做环境变量插值
+// @param data
+// @param format
+// @return *AppConfig
+// @return error
+func ParseAppConfig(data []byte, format ConfigFormat) (*AppConfig, error) {
+	data = expandVars(data)
+	m := make(map[string]any)
+	var err error
+	switch format {
+	case ConfigFormatYaml:
+		err = yaml.Unmarshal(data, &m)
+	case ConfigFormatJson:
+		err = sonic.Unmarshal(data, &m)
+	case ConfigFormatToml:
+		err = toml.Unmarshal(data, &m)
+	default:
+		err = fmt.Errorf("unsupported format %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse app config error: %v", err)
+	}
+	// 统一转成json再映射到App，复用App的json标签
+	d, _ := sonic.Marshal(m)
+	cfg := new(AppConfig)
+	if err = sonic.Unmarshal(d, cfg); err != nil {
+		return nil, fmt.Errorf("parse app config error: %v", err)
+	}
+	seen := make(map[string]bool)
+	for i, app := range cfg.Apps {
+		if err = utils.Validate(app); err != nil {
+			return nil, fmt.Errorf("app config #%d %s error: %v", i, app.Appid, err)
+		}
+		if seen[app.Appid] {
+			return nil, fmt.Errorf("app config duplicate appid %s", app.Appid)
+		}
+		seen[app.Appid] = true
+		cfg.Apps[i] = declaredApp(app)
+	}
+	return cfg, nil
+}
+
+// envRef 仅匹配${NAME}、${NAME:-default}，密钥中单独的$保持原样
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*(?::-[^}]*)?)\}`)
+
+// expandVars 环境变量插值
+func expandVars(data []byte) []byte {
+	return envRef.ReplaceAllFunc(data, func(ref []byte) []byte {
+		return []byte(expandEnv(string(ref[2 : len(ref)-1])))
+	})
+}
+
+// expandEnv 支持 ${ENV:-default}
+func expandEnv(key string) string {
+	if name, def, ok := strings.Cut(key, ":-"); ok {
+		if v, has := os.LookupEnv(name); has && v != "" {
+			return v
+		}
+		return def
+	}
+	return os.Getenv(key)
+}
+
+// declaredApp 丢弃内部维护字段
+func declaredApp(app App) App {
+	return App{
+		AppType:        app.AppType,
+		Appid:          app.Appid,
+		AppSecret:      app.AppSecret,
+		MainAppid:      app.MainAppid,
+		AgentId:        app.AgentId,
+		ComponentAppid: app.ComponentAppid,
+		Token:          app.Token,
+		EncodingAesKey: app.EncodingAesKey,
+		NotifyUri:      app.NotifyUri,
+	}
+}
+
+type PlanAction string
+
+const (
+	PlanActionCreate PlanAction = "create"
+	PlanActionUpdate PlanAction = "update"
+	PlanActionDelete PlanAction = "delete"
+	PlanActionKeep   PlanAction = "keep"
+)
+
+type PlanItem struct {
+	Action PlanAction `json:"action"`
+	Appid  string     `json:"appid"`
+	Fields []string   `json:"fields,omitempty"` // 变更的字段，不包含值，避免泄露密钥
+	Error  string     `json:"error,omitempty"`
+}
+
+// Plan
+// @Description: 配置与存储的差异计划
+type Plan struct {
+	DryRun bool       `json:"dry_run"`
+	Items  []PlanItem `json:"items"`
+}
+
+func (p *Plan) Count(action PlanAction) int {
+	n := 0
+	for _, item := range p.Items {
+		if item.Action == action {
+			n++
+		}
+	}
+	return n
+}
+func (p *Plan) Changed() bool {
+	return p.Count(PlanActionKeep) != len(p.Items)
+}
+func (p *Plan) String() string {
+	buf := utils.NewBuffer()
+	defer buf.Free()
+	_, _ = buf.WriteString("zwx plan")
+	_, _ = buf.WriteStringIf(p.DryRun, " (dry-run)")
+	_, _ = buf.WriteString(fmt.Sprintf(
+		": %d to create, %d to update, %d to delete, %d unchanged",
+		p.Count(PlanActionCreate), p.Count(PlanActionUpdate), p.Count(PlanActionDelete), p.Count(PlanActionKeep),
+	))
+	for _, item := range p.Items {
+		var sign string
+		switch item.Action {
+		case PlanActionCreate:
+			sign = "+"
+		case PlanActionUpdate:
+			sign = "~"
+		case PlanActionDelete:
+			sign = "-"
+		default:
+			continue
+		}
+		_, _ = buf.WriteString(fmt.Sprintf("\n  %s %s %s", sign, item.Action, item.Appid))
+		_, _ = buf.WriteStringIf(len(item.Fields) > 0, " ("+strings.Join(item.Fields, ", ")+")")
+		_, _ = buf.WriteStringIf(item.Error != "", " error: "+item.Error)
+	}
+	return buf.String()
+}
+
+// Reconcile
+// @Description: 对比配置与已托管APP，创建/更新使其一致，cfg.Prune时删除配置中不存在的APP
+// @param cfg
+// @param dryRun 仅生成计划，不做修改
+// @return *Plan
+// @return error 有任一APP处理失败时返回
+func Reconcile(cfg *AppConfig, dryRun bool) (*Plan, error) {
+	plan := &Plan{DryRun: dryRun}
+	declared := make(map[string]App)
+	for _, app := range cfg.Apps {
+		declared[app.Appid] = app
+	}
+	stored := make(map[string]bool)
+	for _, appid := range Appids() {
+		stored[appid] = true
+		if _, ok := declared[appid]; ok || isExternalApp(appid) {
+			continue
+		}
+		if cfg.Prune {
+			plan.Items = append(plan.Items, PlanItem{Action: PlanActionDelete, Appid: appid})
+		} else {
+			plan.Items = append(plan.Items, PlanItem{Action: PlanActionKeep, Appid: appid})
+		}
+	}
+	for _, app := range cfg.Apps {
+		if !stored[app.Appid] {
+			plan.Items = append(plan.Items, PlanItem{Action: PlanActionCreate, Appid: app.Appid})
+			continue
+		}
+		item := PlanItem{Action: PlanActionKeep, Appid: app.Appid}
+		if fields := diffApp(app); len(fields) > 0 {
+			item.Action = PlanActionUpdate
+			item.Fields = fields
+		}
+		plan.Items = append(plan.Items, item)
+	}
+	sort.SliceStable(plan.Items, func(i, j int) bool {
+		return plan.Items[i].Appid < plan.Items[j].Appid
+	})
+	if dryRun {
+		return plan, nil
+	}
+	var failed []string
+	for i, item := range plan.Items {
+		var err error
+		switch item.Action {
+		case PlanActionCreate:
+			err = CreateApp(declared[item.Appid])
+		case PlanActionUpdate:
+			err = UpdateApp(declared[item.Appid])
+		case PlanActionDelete:
+			DeleteApp(item.Appid)
+		}
+		if err != nil {
+			plan.Items[i].Error = err.Error()
+			failed = append(failed, item.Appid)
+		}
+	}
+	if len(failed) > 0 {
+		return plan, fmt.Errorf("reconcile apps %s failed", strings.Join(failed, ","))
+	}
+	return plan, nil
+}
+
+// isExternalApp 租户的APP、第三方平台授权回调创建的APP不受配置文件管理
+func isExternalApp(appid string) bool {
+	wxl.Lock()
+	defer wxl.Unlock()
+	m := wx.storage.HGetAll(PrefixApp.Key(appid))
+	return m["tenant"] != "" || m["component_appid"] != ""
+}
+
+// diffApp 对比声明字段，返回有差异的字段名
+func diffApp(app App) []string {
+	wxl.Lock()
+	old := wx.storage.HGetAll(PrefixApp.Key(app.Appid))
+	wxl.Unlock()
+	m := utils.StructToMap(app)
+	var fields []string
+	for _, k := range appConfigFields {
+		if old[k] != m[k] {
+			fields = append(fields, k)
+		}
+	}
+	return fields
+}
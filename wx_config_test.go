@@ -0,0 +1,174 @@
+package zwx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAppConfig(t *testing.T) {
+	t.Setenv("ZWX_TEST_SECRET", "env-secret")
+	tests := []struct {
+		name    string
+		format  ConfigFormat
+		data    string
+		want    []App
+		wantErr string
+	}{
+		{
+			name:   "yaml with env",
+			format: ConfigFormatYaml,
+			data:   "apps:\n  - app_type: \"1\"\n    appid: wx1\n    app_secret: ${ZWX_TEST_SECRET}\n    token: ${ZWX_TEST_NONE:-dft}\n",
+			want:   []App{{AppType: TypeWxMpServe, Appid: "wx1", AppSecret: "env-secret", Token: "dft"}},
+		},
+		{
+			name:   "toml",
+			format: ConfigFormatToml,
+			data:   "[[apps]]\napp_type = \"3\"\nappid = \"corp1\"\napp_secret = \"s\"\nagent_id = \"1000002\"\n",
+			want:   []App{{AppType: TypeWxWork, Appid: "corp1", AppSecret: "s", AgentId: "1000002"}},
+		},
+		{
+			name:   "json drops internal fields",
+			format: ConfigFormatJson,
+			data:   `{"apps":[{"app_type":"5","appid":"wx3","app_secret":"x","access_token":"zz","tenant":"t1"}]}`,
+			want:   []App{{AppType: TypeWxMiniApp, Appid: "wx3", AppSecret: "x"}},
+		},
+		{
+			name:   "bare dollar kept",
+			format: ConfigFormatJson,
+			data:   `{"apps":[{"app_type":"1","appid":"wx4","app_secret":"a$b$$c${1x}"}]}`,
+			want:   []App{{AppType: TypeWxMpServe, Appid: "wx4", AppSecret: "a$b$$c${1x}"}},
+		},
+		{
+			name:    "duplicate appid",
+			format:  ConfigFormatJson,
+			data:    `{"apps":[{"app_type":"1","appid":"wx1","app_secret":"a"},{"app_type":"1","appid":"wx1","app_secret":"b"}]}`,
+			wantErr: "duplicate appid wx1",
+		},
+		{
+			name:    "missing secret",
+			format:  ConfigFormatJson,
+			data:    `{"apps":[{"app_type":"1","appid":"wx1"}]}`,
+			wantErr: "app config #0 wx1",
+		},
+		{
+			name:    "unsupported format",
+			format:  "ini",
+			data:    "",
+			wantErr: "unsupported format ini",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseAppConfig([]byte(tt.data), tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.Apps, tt.want) {
+				t.Errorf("apps = %+v, want %+v", cfg.Apps, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	setupTest(t)
+	for _, app := range []App{testApp("keep"), testApp("update"), testApp("delete")} {
+		if err := CreateApp(app); err != nil {
+			t.Fatal(err)
+		}
+	}
+	external := testApp("tenant-app")
+	external.Tenant = "t1"
+	if err := CreateApp(external); err != nil {
+		t.Fatal(err)
+	}
+	changed := testApp("update")
+	changed.Token = "new-token"
+	cfg := &AppConfig{Apps: []App{testApp("create"), testApp("keep"), changed}}
+
+	tests := []struct {
+		name   string
+		dryRun bool
+		prune  bool
+		want   []PlanItem
+	}{
+		{
+			name:   "keep undeclared by default",
+			dryRun: true,
+			want: []PlanItem{
+				{Action: PlanActionCreate, Appid: "create"},
+				{Action: PlanActionKeep, Appid: "delete"},
+				{Action: PlanActionKeep, Appid: "keep"},
+				{Action: PlanActionUpdate, Appid: "update", Fields: []string{"token"}},
+			},
+		},
+		{
+			name:   "dry run with prune",
+			dryRun: true,
+			prune:  true,
+			want: []PlanItem{
+				{Action: PlanActionCreate, Appid: "create"},
+				{Action: PlanActionDelete, Appid: "delete"},
+				{Action: PlanActionKeep, Appid: "keep"},
+				{Action: PlanActionUpdate, Appid: "update", Fields: []string{"token"}},
+			},
+		},
+		{
+			name:  "apply with prune",
+			prune: true,
+			want: []PlanItem{
+				{Action: PlanActionCreate, Appid: "create"},
+				{Action: PlanActionDelete, Appid: "delete"},
+				{Action: PlanActionKeep, Appid: "keep"},
+				{Action: PlanActionUpdate, Appid: "update", Fields: []string{"token"}},
+			},
+		},
+		{
+			name:   "converged",
+			dryRun: true,
+			prune:  true,
+			want: []PlanItem{
+				{Action: PlanActionKeep, Appid: "create"},
+				{Action: PlanActionKeep, Appid: "keep"},
+				{Action: PlanActionKeep, Appid: "update"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Prune = tt.prune
+			plan, err := Reconcile(cfg, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(plan.Items, tt.want) {
+				t.Errorf("items = %+v, want %+v", plan.Items, tt.want)
+			}
+		})
+	}
+	if _, err := LoadApp("tenant-app"); err != nil {
+		t.Errorf("tenant app should not be managed by config: %v", err)
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	p := &Plan{DryRun: true, Items: []PlanItem{
+		{Action: PlanActionCreate, Appid: "a"},
+		{Action: PlanActionUpdate, Appid: "b", Fields: []string{"token", "app_secret"}},
+		{Action: PlanActionKeep, Appid: "c"},
+	}}
+	want := "zwx plan (dry-run): 1 to create, 1 to update, 0 to delete, 1 unchanged\n  + create a\n  ~ update b (token, app_secret)"
+	if got := p.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+	if !p.Changed() {
+		t.Error("Changed() = false, want true")
+	}
+}
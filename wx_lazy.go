@@ -0,0 +1,93 @@
+package zwx
+
+import (
+	"github.com/zohu/zwx/utils"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// activeMarkInterval 同一APP在该间隔内只写一次活跃时间，避免每次调用都写存储
+const activeMarkInterval = time.Minute
+
+// markActive 记录APP最近使用时间，过期即视为冷APP
+func (c *Context) markActive() {
+	now := utils.Now()
+	if last, ok := c.activeMarks.Load(c.Appid()); ok && now.Sub(last.(time.Time)) < activeMarkInterval {
+		return
+	}
+	c.activeMarks.Store(c.Appid(), now)
+	c.storage.SetEX(PrefixActive.Key(c.Appid()), strconv.FormatInt(now.Unix(), 10), c.activeWindow)
+}
+
+// LastActive
+// @Description: 按需模式下APP最近使用时间，活跃窗口内未使用返回零值
+// @receiver c
+// @return time.Time
+func (c *Context) LastActive() time.Time {
+	ts, err := strconv.ParseInt(c.storage.Get(PrefixActive.Key(c.Appid())), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
+
+// evictToken 释放token，下次使用时重新获取
+func (c *Context) evictToken() {
+	c.Lock()
+	defer c.Unlock()
+	if c.app.AccessToken == "" {
+		return
+	}
+	c.app.AccessToken = ""
+	c.app.JsTicket = ""
+	c.app.CardTicket = ""
+	c.app.AgentTicket = ""
+	c.app.ExpireTime = utils.Now()
+	wxl.Lock()
+	defer wxl.Unlock()
+	c.storage.HSet(PrefixApp.Key(c.Appid()), map[string]string{
+		"access_token": "",
+		"js_ticket":    "",
+		"card_ticket":  "",
+		"agent_ticket": "",
+		"expire_time":  c.app.ExpireTime.Format(time.RFC3339Nano),
+	})
+}
+
+// activeAppids 按需模式下需要刷新的APP，冷APP以及超出MaxActiveApps的最久未使用APP会被释放token
+func (wx *Wx) activeAppids(appids []string) []string {
+	type active struct {
+		appid string
+		last  int64
+	}
+	var hot []active
+	var cold []string
+	for _, appid := range appids {
+		if last, err := strconv.ParseInt(wx.storage.Get(PrefixActive.Key(appid)), 10, 64); err == nil {
+			hot = append(hot, active{appid: appid, last: last})
+		} else {
+			cold = append(cold, appid)
+		}
+	}
+	if wx.maxActiveApps > 0 && len(hot) > wx.maxActiveApps {
+		sort.Slice(hot, func(i, j int) bool {
+			return hot[i].last > hot[j].last
+		})
+		for _, a := range hot[wx.maxActiveApps:] {
+			cold = append(cold, a.appid)
+		}
+		hot = hot[:wx.maxActiveApps]
+	}
+	for _, appid := range cold {
+		if c, err := LoadApp(appid); err == nil && c.app.AccessToken != "" {
+			wx.logger.Debugf("evict cold app %s token", appid)
+			c.evictToken()
+		}
+	}
+	list := make([]string, 0, len(hot))
+	for _, a := range hot {
+		list = append(list, a.appid)
+	}
+	return list
+}
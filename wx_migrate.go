@@ -0,0 +1,268 @@
+package zwx
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zohu/zwx/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Migration
+// @Description: 存储结构迁移步骤
+type Migration struct {
+	// 唯一名称，业务迁移按名称记录是否已执行
+	Name string
+	// 迁移逻辑，s已带StoragePrefix，需保证可重复执行
+	Up func(s Storage) error
+	// 可选，校验迁移结果
+	Verify func(s Storage) error
+}
+
+var (
+	migrationsMu sync.Mutex
+	// schemaMigrations zwx自身的迁移，第i个为版本i+1，只追加不修改；
+	// App新增可选字段无需迁移，LoadApp对缺失字段取零值
+	schemaMigrations = []Migration{
+		{Name: "baseline app fields", Up: migrateBaseline, Verify: verifyAppFields},
+	}
+	// userMigrations 业务注册的迁移，按注册顺序在zwx迁移之后执行，与zwx版本号互不影响
+	userMigrations []Migration
+)
+
+// RegisterMigration
+// @Description: 注册业务迁移，需在New之前调用，按名称记录是否已执行，名称重复或缺少Up时返回错误
+// @param m
+// @return error
+func RegisterMigration(m Migration) error {
+	if m.Name == "" {
+		return errors.New("zwx migration name required")
+	}
+	if m.Up == nil {
+		return fmt.Errorf("zwx migration %s has no up step", m.Name)
+	}
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	for _, um := range userMigrations {
+		if um.Name == m.Name {
+			return fmt.Errorf("zwx migration %s already registered", m.Name)
+		}
+	}
+	userMigrations = append(userMigrations, m)
+	return nil
+}
+
+// LatestSchemaVersion
+// @Description: 当前代码支持的zwx存储结构版本，不包含业务迁移
+// @return int
+func LatestSchemaVersion() int {
+	return len(schemaMigrations)
+}
+
+// SchemaVersion
+// @Description: 存储中记录的zwx存储结构版本，未记录为0
+// @return int
+func SchemaVersion() int {
+	v, _ := strconv.Atoi(wx.storage.HGetAll(PrefixSchema.Key())["version"])
+	return v
+}
+
+// appliedUserMigrations 已执行的业务迁移，名称 -> 执行时间
+func appliedUserMigrations() map[string]string {
+	return wx.storage.HGetAll(PrefixSchema.Key("user"))
+}
+
+// pendingUserMigrations 未执行的业务迁移
+func pendingUserMigrations() []Migration {
+	applied := appliedUserMigrations()
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	var pending []Migration
+	for _, m := range userMigrations {
+		if _, ok := applied[m.Name]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// MigrationReport
+// @Description: 迁移结果
+type MigrationReport struct {
+	DryRun  bool     `json:"dry_run"`
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Applied []string `json:"applied"`
+}
+
+func (r *MigrationReport) String() string {
+	if len(r.Applied) == 0 {
+		return fmt.Sprintf("zwx schema is up to date (v%d)", r.To)
+	}
+	prefix := "zwx schema migrated"
+	if r.DryRun {
+		prefix = "zwx schema pending (dry-run)"
+	}
+	return fmt.Sprintf("%s v%d -> v%d:\n  %s", prefix, r.From, r.To, strings.Join(r.Applied, "\n  "))
+}
+
+// Migrate
+// @Description: 依次执行未应用的zwx迁移和业务迁移，同一存储同时只允许一个实例迁移
+// @param dryRun 仅列出待执行的迁移
+// @return *MigrationReport
+// @return error
+func Migrate(dryRun bool) (*MigrationReport, error) {
+	from := SchemaVersion()
+	report := &MigrationReport{DryRun: dryRun, From: from, To: from}
+	if from > LatestSchemaVersion() {
+		return report, fmt.Errorf("storage schema v%d is newer than supported v%d", from, LatestSchemaVersion())
+	}
+	if !dryRun {
+		if !wx.storage.SetNX(PrefixSchema.Key("lock"), "migrating", 10*time.Minute) {
+			return report, fmt.Errorf("another migration is running")
+		}
+		defer wx.storage.Del(PrefixSchema.Key("lock"))
+	}
+	for i := from; i < len(schemaMigrations); i++ {
+		m, version := schemaMigrations[i], i+1
+		report.Applied = append(report.Applied, fmt.Sprintf("v%d %s", version, m.Name))
+		report.To = version
+		if dryRun {
+			continue
+		}
+		if err := m.Up(wx.storage); err != nil {
+			report.To = version - 1
+			return report, fmt.Errorf("migration v%d %s error: %v", version, m.Name, err)
+		}
+		wx.storage.HSet(PrefixSchema.Key(), map[string]string{
+			"version":    strconv.Itoa(version),
+			"updated_at": time.Now().Format(time.RFC3339),
+		})
+		wx.logger.Infof("zwx schema migrated to v%d %s", version, m.Name)
+	}
+	for _, m := range pendingUserMigrations() {
+		report.Applied = append(report.Applied, "user "+m.Name)
+		if dryRun {
+			continue
+		}
+		if err := m.Up(wx.storage); err != nil {
+			return report, fmt.Errorf("migration %s error: %v", m.Name, err)
+		}
+		wx.storage.HSet(PrefixSchema.Key("user"), map[string]string{m.Name: time.Now().Format(time.RFC3339)})
+		wx.logger.Infof("zwx user migration %s applied", m.Name)
+	}
+	return report, nil
+}
+
+// VerifySchema
+// @Description: 校验存储结构版本、业务迁移与全部迁移结果
+// @return error
+func VerifySchema() error {
+	if v := SchemaVersion(); v != LatestSchemaVersion() {
+		return fmt.Errorf("storage schema v%d, expect v%d", v, LatestSchemaVersion())
+	}
+	var errs []string
+	for _, m := range pendingUserMigrations() {
+		errs = append(errs, fmt.Sprintf("user %s: not applied", m.Name))
+	}
+	migrationsMu.Lock()
+	list := append(append([]Migration(nil), schemaMigrations...), userMigrations...)
+	migrationsMu.Unlock()
+	for _, m := range list {
+		if m.Verify == nil {
+			continue
+		}
+		if err := m.Verify(wx.storage); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", m.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("verify schema failed:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// RenameAppField
+// @Description: 迁移辅助，重命名全部APP的hash字段，新字段已有非空值时不覆盖，旧字段置空
+// @param from
+// @param to
+// @return func(s Storage) error
+func RenameAppField(from, to string) func(s Storage) error {
+	return func(s Storage) error {
+		return forEachApp(s, func(appid string, m map[string]string) error {
+			v := m[from]
+			if v == "" {
+				return nil
+			}
+			if m[to] == "" {
+				s.HSet(PrefixApp.Key(appid), map[string]string{to: v})
+			}
+			// Storage不支持HDel，旧字段置空，LoadApp忽略空值
+			s.HSet(PrefixApp.Key(appid), map[string]string{from: ""})
+			return nil
+		})
+	}
+}
+
+func forEachApp(s Storage, fn func(appid string, m map[string]string) error) error {
+	appids := s.SMembers(PrefixAppList.Key())
+	sort.Strings(appids)
+	for _, appid := range appids {
+		m := s.HGetAll(PrefixApp.Key(appid))
+		if len(m) == 0 {
+			continue
+		}
+		if err := fn(appid, m); err != nil {
+			return fmt.Errorf("app %s: %v", appid, err)
+		}
+	}
+	return nil
+}
+
+// migrateBaseline 清理丢失hash的appid，补齐App的全部字段
+func migrateBaseline(s Storage) error {
+	defaults := utils.StructToMap(App{Retry: "0"})
+	for _, appid := range s.SMembers(PrefixAppList.Key()) {
+		if len(s.HGetAll(PrefixApp.Key(appid))) == 0 {
+			s.SRem(PrefixAppList.Key(), appid)
+		}
+	}
+	return forEachApp(s, func(appid string, m map[string]string) error {
+		missing := make(map[string]string)
+		for k, v := range defaults {
+			if _, ok := m[k]; !ok {
+				missing[k] = v
+			}
+		}
+		if len(missing) > 0 {
+			s.HSet(PrefixApp.Key(appid), missing)
+		}
+		return nil
+	})
+}
+
+// verifyAppFields 每个appid都有hash且包含必需字段，新增的可选字段允许缺失
+func verifyAppFields(s Storage) error {
+	required := []string{"app_type", "appid", "app_secret"}
+	var errs []string
+	for _, appid := range s.SMembers(PrefixAppList.Key()) {
+		m := s.HGetAll(PrefixApp.Key(appid))
+		if len(m) == 0 {
+			errs = append(errs, appid+" not found")
+			continue
+		}
+		for _, k := range required {
+			if m[k] == "" {
+				errs = append(errs, appid+" missing "+k)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return nil
+}
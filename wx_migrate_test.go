@@ -0,0 +1,165 @@
+package zwx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// resetUserMigrations 恢复注册前的业务迁移列表
+func resetUserMigrations(t *testing.T) {
+	t.Helper()
+	migrationsMu.Lock()
+	saved := userMigrations
+	userMigrations = nil
+	migrationsMu.Unlock()
+	t.Cleanup(func() {
+		migrationsMu.Lock()
+		userMigrations = saved
+		migrationsMu.Unlock()
+	})
+}
+
+func TestRegisterMigration(t *testing.T) {
+	resetUserMigrations(t)
+	up := func(s Storage) error { return nil }
+	tests := []struct {
+		name    string
+		m       Migration
+		wantErr string
+	}{
+		{"ok", Migration{Name: "a", Up: up}, ""},
+		{"duplicate", Migration{Name: "a", Up: up}, "already registered"},
+		{"no name", Migration{Up: up}, "name required"},
+		{"no up", Migration{Name: "b"}, "has no up step"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterMigration(tt.m)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	resetUserMigrations(t)
+	setupTest(t)
+	app := testApp("wx1")
+	app.Token = "tk"
+	if err := CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	runs := 0
+	if err := RegisterMigration(Migration{
+		Name: "rename token",
+		Up: func(s Storage) error {
+			runs++
+			return RenameAppField("token", "notify_token")(s)
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterMigration(Migration{
+		Name: "checked",
+		Up:   func(s Storage) error { return nil },
+		Verify: func(s Storage) error {
+			return errors.New("always fails")
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		wantApplied []string
+		wantRuns    int
+	}{
+		{"dry run", true, []string{"user rename token", "user checked"}, 0},
+		{"apply", false, []string{"user rename token", "user checked"}, 1},
+		{"already applied", false, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Migrate(tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(report.Applied, ",") != strings.Join(tt.wantApplied, ",") {
+				t.Errorf("applied = %v, want %v", report.Applied, tt.wantApplied)
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("runs = %d, want %d", runs, tt.wantRuns)
+			}
+			if report.To != LatestSchemaVersion() {
+				t.Errorf("to = v%d, want v%d", report.To, LatestSchemaVersion())
+			}
+		})
+	}
+	err := VerifySchema()
+	if err == nil || !strings.Contains(err.Error(), "checked: always fails") {
+		t.Errorf("VerifySchema err = %v, want verify failure", err)
+	}
+	if m := wx.storage.HGetAll(PrefixApp.Key("wx1")); m["token"] != "" || m["notify_token"] != "tk" {
+		t.Errorf("renamed fields = %v", m)
+	}
+}
+
+func TestVerifySchemaPending(t *testing.T) {
+	resetUserMigrations(t)
+	setupTest(t)
+	if err := VerifySchema(); err != nil {
+		t.Fatalf("fresh storage: %v", err)
+	}
+	if err := RegisterMigration(Migration{Name: "late", Up: func(s Storage) error { return nil }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySchema(); err == nil || !strings.Contains(err.Error(), "user late: not applied") {
+		t.Errorf("err = %v, want pending user migration", err)
+	}
+}
+
+func TestMigrationReportString(t *testing.T) {
+	tests := []struct {
+		name string
+		r    MigrationReport
+		want string
+	}{
+		{"up to date", MigrationReport{From: 1, To: 1}, "zwx schema is up to date (v1)"},
+		{"migrated", MigrationReport{From: 0, To: 1, Applied: []string{"v1 baseline app fields"}}, "zwx schema migrated v0 -> v1:\n  v1 baseline app fields"},
+		{"dry run", MigrationReport{DryRun: true, From: 1, To: 1, Applied: []string{"user a"}}, "zwx schema pending (dry-run) v1 -> v1:\n  user a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAppSkipsEmptyFields(t *testing.T) {
+	setupTest(t)
+	app := testApp("wx1")
+	app.Token = "tk"
+	if err := CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	// 迁移清除的字段以空值保留，time字段的空串不能中断其余字段的解析
+	wx.storage.HSet(PrefixApp.Key("wx1"), map[string]string{"expire_time": "", "breaker_opened_at": ""})
+	c, err := LoadApp("wx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Info(); got.Token != "tk" || got.AppSecret != app.AppSecret || !got.ExpireTime.IsZero() {
+		t.Errorf("app = %+v", got)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/valkey-io/valkey-go"
+	"github.com/valyala/fasthttp"
+	"github.com/zohu/zwx/utils"
 	"log/slog"
 	"os"
 	"time"
@@ -45,6 +47,42 @@ type Options struct {
 	AccessTokenRefresh time.Duration
 	// 每次启动前清理缓存，默认false，如果开启，每次启动之前都会遗忘之前托管的app
 	AlwaysCleanBeforeStart bool
+	// 声明式APP配置文件(yaml/json/toml)，启动时与已托管APP对比并创建/更新
+	AppConfigFile string
+	// 启动时删除配置文件中不存在的APP，默认false，与命令行import的-prune一致
+	AppConfigPrune bool
+	// 仅打印配置差异计划，不做修改
+	AppConfigDryRun bool
+	// 启动时自动执行存储结构迁移，默认false，仅在存储为空时自动写入版本
+	AutoMigrate bool
+	// 启动时不执行任何迁移，也不在存储为空时写入版本，适用于命令行只读命令
+	DisableMigrate bool
+	// 租户操作审计，默认输出到日志
+	TenantAudit TenantAuditFunc
+	// 连续鉴权失败(如secret被重置、IP不在白名单)多少次后熔断，默认5
+	BreakerThreshold int
+	// 熔断后多久进入半开状态尝试探测，默认10分钟
+	BreakerCooldown time.Duration
+	// 按需获取token，创建时不获取，首次使用时获取，后台仅刷新活跃窗口内使用过的APP，适用于大量闲置APP
+	LazyToken bool
+	// 按需模式的活跃窗口，超过该时长未使用的APP会释放token，默认24小时
+	ActiveWindow time.Duration
+	// 按需模式最多保持token的APP数量，超出时按最近使用时间淘汰，默认0不限制
+	MaxActiveApps int
+	// 分片刷新，所有存活实例通过存储心跳组成集群，按一致性哈希各自只刷新分到的APP
+	ShardedRefresh bool
+	// 分片刷新的心跳间隔，默认10秒，超过3倍间隔未心跳的实例视为下线
+	HeartbeatInterval time.Duration
+	// 分片刷新中的实例ID，默认 hostname-pid-随机串
+	InstanceID string
+	// 请求重试策略，默认GET等幂等请求在网络异常、系统繁忙时最多尝试3次
+	RetryPolicy *RetryPolicy
+	// 请求微信接口使用的HTTP客户端，如需代理、自定义拨号或TLS配置时设置，默认每次请求新建
+	HttpClient *fasthttp.Client
+	// 随机数与时钟来源，用于nonce、时间戳、加密随机前缀等，默认基于crypto/rand，测试中可使用utils.NewSeededProvider生成可复现的报文
+	Provider utils.Provider
+	// 不启动后台刷新token，适用于命令行等短生命周期进程
+	DisableAutoRefresh bool
 }
 
 func (o *Options) Validate() {
@@ -61,6 +99,25 @@ func (o *Options) Validate() {
 	if o.AccessTokenRefresh == 0 {
 		o.AccessTokenRefresh = 55 * time.Minute
 	}
+	if o.ActiveWindow == 0 {
+		o.ActiveWindow = 24 * time.Hour
+	}
+	if o.HeartbeatInterval == 0 {
+		o.HeartbeatInterval = 10 * time.Second
+	}
+	if o.BreakerThreshold == 0 {
+		o.BreakerThreshold = 5
+	}
+	if o.BreakerCooldown == 0 {
+		o.BreakerCooldown = 10 * time.Minute
+	}
+}
+
+// DefaultLogger
+// @Description: 默认日志实现，基于slog，Fatalf输出后退出进程
+// @return Logger
+func DefaultLogger() Logger {
+	return &defaultLogger{}
 }
 
 // 默认日志实现
@@ -0,0 +1,109 @@
+package zwx
+
+import (
+	"fmt"
+	"github.com/zohu/zwx/utils"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// shardReplicas 每个实例在哈希环上的虚拟节点数
+const shardReplicas = 100
+
+// InstanceID
+// @Description: 当前实例在分片刷新中的ID
+// @return string
+func InstanceID() string {
+	return wx.instanceID
+}
+
+// Members
+// @Description: 分片刷新中存活的实例，按ID排序
+// @return []string
+func Members() []string {
+	var live []string
+	for _, id := range wx.storage.SMembers(PrefixMember.Key()) {
+		if wx.storage.Get(PrefixMember.Key(id)) != "" {
+			live = append(live, id)
+		} else {
+			// 心跳过期，移出成员列表
+			wx.storage.SRem(PrefixMember.Key(), id)
+		}
+	}
+	sort.Strings(live)
+	return live
+}
+
+// Shutdown
+// @Description: 停止后台刷新，分片模式下同时退出分片，其余实例在下一轮刷新时接管本实例的APP
+func Shutdown() {
+	wx.stopOnce.Do(func() {
+		close(wx.stop)
+	})
+	if !wx.sharded {
+		return
+	}
+	wx.storage.SRem(PrefixMember.Key(), wx.instanceID)
+	wx.storage.Del(PrefixMember.Key(wx.instanceID))
+	wx.logger.Infof("instance %s left sharded refresh", wx.instanceID)
+}
+
+func defaultInstanceID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), utils.RandomStr(6))
+}
+
+func (wx *Wx) heartbeat() {
+	wx.storage.SAdd(PrefixMember.Key(), wx.instanceID)
+	wx.storage.SetEX(PrefixMember.Key(wx.instanceID), strconv.FormatInt(utils.Now().Unix(), 10), 3*wx.heartbeatInterval)
+}
+
+func (wx *Wx) heartbeatLoop() {
+	defer func() {
+		if r := recover(); r != nil {
+			wx.logger.Errorf("heartbeat panic: %v", r)
+			wx.heartbeatLoop()
+		}
+	}()
+	ticker := time.NewTicker(wx.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-wx.stop:
+			return
+		case <-ticker.C:
+			wx.heartbeat()
+		}
+	}
+}
+
+// ownedAppids 按一致性哈希分到本实例的APP
+func (wx *Wx) ownedAppids(appids []string) []string {
+	members := Members()
+	found := false
+	for _, id := range members {
+		if id == wx.instanceID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		members = append(members, wx.instanceID)
+	}
+	ring := utils.NewHashRing(shardReplicas, members...)
+	var owned []string
+	for _, appid := range appids {
+		if ring.Get(appid) == wx.instanceID {
+			owned = append(owned, appid)
+		}
+	}
+	wx.logger.Debugf("instance %s owns %d/%d apps across %d members", wx.instanceID, len(owned), len(appids), len(members))
+	return owned
+}
+
+// claimRefresh 成员变化期间可能有两个实例同时认为拥有某APP，刷新前加锁去重
+func (wx *Wx) claimRefresh(appid string) bool {
+	return wx.storage.SetNX(PrefixRetry.Key(appid, "refresh"), wx.instanceID, wx.accessTokenRefresh/2)
+}
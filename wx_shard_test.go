@@ -0,0 +1,66 @@
+package zwx
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestOwnedAppids(t *testing.T) {
+	setupTest(t)
+	appids := make([]string, 200)
+	for i := range appids {
+		appids[i] = fmt.Sprintf("wx%03d", i)
+	}
+	tests := []struct {
+		name    string
+		members []string
+	}{
+		{"alone", nil},
+		{"two instances", []string{"node-a", "node-b"}},
+		{"three instances", []string{"node-a", "node-b", "node-c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wx.storage.Del(PrefixMember.Key())
+			for _, id := range tt.members {
+				wx.storage.SAdd(PrefixMember.Key(), id)
+				wx.storage.SetEX(PrefixMember.Key(id), "1", time.Minute)
+			}
+			self := wx.instanceID
+			t.Cleanup(func() { wx.instanceID = self })
+			if len(tt.members) == 0 {
+				if owned := wx.ownedAppids(appids); len(owned) != len(appids) {
+					t.Errorf("single instance owns %d/%d apps", len(owned), len(appids))
+				}
+				return
+			}
+			// 每个APP恰好属于一个实例
+			owner := make(map[string]string)
+			for _, id := range tt.members {
+				wx.instanceID = id
+				for _, appid := range wx.ownedAppids(appids) {
+					if prev, ok := owner[appid]; ok {
+						t.Fatalf("%s owned by %s and %s", appid, prev, id)
+					}
+					owner[appid] = id
+				}
+			}
+			if len(owner) != len(appids) {
+				t.Errorf("%d/%d apps owned", len(owner), len(appids))
+			}
+		})
+	}
+}
+
+func TestShutdownStopsRefresh(t *testing.T) {
+	setupTest(t)
+	Shutdown()
+	select {
+	case <-wx.stop:
+	default:
+		t.Fatal("stop channel not closed in non-sharded mode")
+	}
+	// 重复调用不panic
+	Shutdown()
+}
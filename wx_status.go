@@ -0,0 +1,75 @@
+package zwx
+
+import (
+	"github.com/zohu/zwx/utils"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AppStatus
+// @Description: APP的token健康状态，时间类字段单位为秒
+type AppStatus struct {
+	Appid       string       `json:"appid"`
+	AppType     AppType      `json:"app_type"`
+	Valid       bool         `json:"valid"`        // 是否持有未过期的token
+	TokenAge    int64        `json:"token_age"`    // 距上次刷新成功的时长
+	RefreshTime time.Time    `json:"refresh_time"` // 上次刷新成功的时间
+	ExpireTime  time.Time    `json:"expire_time"`
+	ExpireIn    int64        `json:"expire_in"` // 剩余有效期
+	Failures    int64        `json:"failures"`  // 连续刷新失败次数
+	Breaker     BreakerState `json:"breaker"`
+}
+
+// Status
+// @Description: 获取APP的token健康状态
+// @receiver c
+// @return *AppStatus
+func (c *Context) Status() *AppStatus {
+	now := utils.Now()
+	st := &AppStatus{
+		Appid:       c.app.Appid,
+		AppType:     c.app.AppType,
+		Valid:       c.app.AccessToken != "" && c.app.ExpireTime.After(now),
+		RefreshTime: c.app.RefreshTime,
+		ExpireTime:  c.app.ExpireTime,
+	}
+	if !c.app.RefreshTime.IsZero() {
+		st.TokenAge = int64(now.Sub(c.app.RefreshTime).Seconds())
+	}
+	if st.Valid {
+		st.ExpireIn = int64(c.app.ExpireTime.Sub(now).Seconds())
+	}
+	st.Failures, _ = strconv.ParseInt(c.app.Retry, 10, 64)
+	st.Breaker = c.BreakerState()
+	return st
+}
+
+// Statuses
+// @Description: 获取全部已托管APP的健康状态，按appid排序
+// @return []*AppStatus
+func Statuses() []*AppStatus {
+	appids := Appids()
+	sort.Strings(appids)
+	list := make([]*AppStatus, 0, len(appids))
+	for _, appid := range appids {
+		if c, err := LoadApp(appid); err == nil {
+			list = append(list, c.Status())
+		}
+	}
+	return list
+}
+
+// LastRefresh
+// @Description: 后台刷新最近一次完成的时间，未开启后台刷新时为初始化时间
+// @return time.Time
+func LastRefresh() time.Time {
+	return time.Unix(wx.refreshedAt.Load(), 0)
+}
+
+// RefreshInterval
+// @Description: 后台刷新token的间隔
+// @return time.Duration
+func RefreshInterval() time.Duration {
+	return wx.accessTokenRefresh
+}
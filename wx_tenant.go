@@ -0,0 +1,206 @@
+package zwx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TenantAuditFunc
+// @Description: 租户操作审计，action为create/update/delete/quota
+type TenantAuditFunc func(tenant, action, appid string)
+
+// Tenant
+// @Description: 租户命名空间，租户间的APP列表、配额、审计相互隔离，不能跨租户解析APP
+type Tenant struct {
+	id string
+}
+
+// ForTenant
+// @Description: 获取租户上下文
+// @param id 租户ID，不能为空且不能包含冒号
+// @return *Tenant
+func ForTenant(id string) *Tenant {
+	return &Tenant{id: id}
+}
+
+// Tenants
+// @Description: 获取全部租户
+// @return []string
+func Tenants() []string {
+	return wx.storage.SMembers(PrefixTenant.Key())
+}
+
+func (t *Tenant) ID() string {
+	return t.id
+}
+
+// LoadApp
+// @Description: 获取本租户的APP实例，其它租户的appid视为不存在
+// @receiver t
+// @param appid
+// @return *Context
+// @return error
+func (t *Tenant) LoadApp(appid string) (*Context, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	c, err := LoadApp(appid)
+	if err != nil || c.app.Tenant != t.id {
+		return nil, fmt.Errorf("appid %s not found", appid)
+	}
+	return c, nil
+}
+
+// CreateApp
+// @Description: 在本租户下创建并托管APP，已托管的appid(含本租户)不能创建，本租户的APP请使用UpdateApp
+// @receiver t
+// @param app
+// @return error
+func (t *Tenant) CreateApp(app App) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	// 同一appid同时只允许一个租户认领
+	claim := PrefixTenant.Key("claim", app.Appid)
+	if !wx.storage.SetNX(claim, t.id, 30*time.Second) {
+		return fmt.Errorf("create app %s error: appid is being created", app.Appid)
+	}
+	defer wx.storage.Del(claim)
+	// 配额检查与写入租户APP列表需在租户维度串行，避免并发创建不同appid同时通过检查
+	unlock, err := t.lock()
+	if err != nil {
+		return fmt.Errorf("create app %s error: %v", app.Appid, err)
+	}
+	defer unlock()
+	if c, err := LoadApp(app.Appid); err == nil && c.app.Tenant != t.id {
+		return fmt.Errorf("create app %s error: appid already hosted", app.Appid)
+	} else if err == nil {
+		return fmt.Errorf("create app %s error: appid already exists, use UpdateApp", app.Appid)
+	}
+	if quota := t.Quota(); quota > 0 && len(t.Appids()) >= quota {
+		return fmt.Errorf("create app %s error: tenant %s quota %d exceeded", app.Appid, t.id, quota)
+	}
+	app.Tenant = t.id
+	wx.storage.SAdd(PrefixTenant.Key(), t.id)
+	wx.storage.SAdd(PrefixTenant.Key(t.id, "apps"), app.Appid)
+	if err := CreateApp(app); err != nil {
+		wx.storage.SRem(PrefixTenant.Key(t.id, "apps"), app.Appid)
+		return err
+	}
+	t.audit("create", app.Appid)
+	return nil
+}
+
+// UpdateApp
+// @Description: 更新本租户的APP配置
+// @receiver t
+// @param app
+// @return error
+func (t *Tenant) UpdateApp(app App) error {
+	if _, err := t.LoadApp(app.Appid); err != nil {
+		return fmt.Errorf("update app %s error: %v", app.Appid, err)
+	}
+	if err := UpdateApp(app); err != nil {
+		return err
+	}
+	t.audit("update", app.Appid)
+	return nil
+}
+
+// DeleteApp
+// @Description: 停止托管本租户的APP
+// @receiver t
+// @param appid
+// @return error
+func (t *Tenant) DeleteApp(appid string) error {
+	if _, err := t.LoadApp(appid); err != nil {
+		return fmt.Errorf("delete app %s error: %v", appid, err)
+	}
+	DeleteApp(appid)
+	t.audit("delete", appid)
+	return nil
+}
+
+// DeleteAll
+// @Description: 停止托管本租户的全部APP，并移除租户
+// @receiver t
+// @return int 删除的APP数量
+func (t *Tenant) DeleteAll() int {
+	n := 0
+	for _, appid := range t.Appids() {
+		if t.DeleteApp(appid) == nil {
+			n++
+		} else {
+			// 已被删除或不属于本租户的残留成员
+			wx.storage.SRem(PrefixTenant.Key(t.id, "apps"), appid)
+		}
+	}
+	wx.storage.Del(PrefixTenant.Key(t.id, "apps"))
+	wx.storage.Del(PrefixTenant.Key(t.id))
+	wx.storage.SRem(PrefixTenant.Key(), t.id)
+	return n
+}
+
+// Appids
+// @Description: 获取本租户已托管APPID列表
+// @receiver t
+// @return []string
+func (t *Tenant) Appids() []string {
+	if t.validate() != nil {
+		return nil
+	}
+	return wx.storage.SMembers(PrefixTenant.Key(t.id, "apps"))
+}
+
+// SetQuota
+// @Description: 设置本租户可托管的APP数量上限，0为不限制
+// @receiver t
+// @param quota
+func (t *Tenant) SetQuota(quota int) {
+	if t.validate() != nil {
+		return
+	}
+	wx.storage.SAdd(PrefixTenant.Key(), t.id)
+	wx.storage.HSet(PrefixTenant.Key(t.id), map[string]string{"quota": strconv.Itoa(quota)})
+	t.audit("quota", strconv.Itoa(quota))
+}
+
+func (t *Tenant) Quota() int {
+	quota, _ := strconv.Atoi(wx.storage.HGetAll(PrefixTenant.Key(t.id))["quota"])
+	return quota
+}
+
+// tenantLockWait 等待租户锁的最长时间，按重试次数计算，不依赖时钟
+const (
+	tenantLockWait     = 3 * time.Second
+	tenantLockInterval = 50 * time.Millisecond
+)
+
+// lock 租户级互斥锁，持有方异常退出时30秒后自动释放
+func (t *Tenant) lock() (func(), error) {
+	key := PrefixTenant.Key(t.id, "lock")
+	for i := 0; !wx.storage.SetNX(key, "locked", 30*time.Second); i++ {
+		if i >= int(tenantLockWait/tenantLockInterval) {
+			return nil, fmt.Errorf("tenant %s is busy", t.id)
+		}
+		time.Sleep(tenantLockInterval)
+	}
+	return func() { wx.storage.Del(key) }, nil
+}
+
+func (t *Tenant) validate() error {
+	if t.id == "" || strings.Contains(t.id, ":") {
+		return fmt.Errorf("invalid tenant id %q", t.id)
+	}
+	return nil
+}
+
+func (t *Tenant) audit(action, appid string) {
+	if wx.tenantAudit != nil {
+		wx.tenantAudit(t.id, action, appid)
+		return
+	}
+	wx.logger.Infof("tenant %s %s %s", t.id, action, appid)
+}
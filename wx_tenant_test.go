@@ -0,0 +1,103 @@
+package zwx
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTenant(t *testing.T) {
+	setupTest(t)
+	t1, t2 := ForTenant("t1"), ForTenant("t2")
+	t1.SetQuota(2)
+	if err := t1.CreateApp(testApp("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateApp(testApp("platform")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		run     func() error
+		wantErr string
+	}{
+		{"load own app", func() error { _, err := t1.LoadApp("a"); return err }, ""},
+		{"load other tenant app", func() error { _, err := t2.LoadApp("a"); return err }, "appid a not found"},
+		{"load platform app", func() error { _, err := t1.LoadApp("platform"); return err }, "appid platform not found"},
+		{"invalid tenant id", func() error { _, err := ForTenant("a:b").LoadApp("a"); return err }, "invalid tenant id"},
+		{"create hosted by other tenant", func() error { return t2.CreateApp(testApp("a")) }, "appid already hosted"},
+		{"create hosted by platform", func() error { return t1.CreateApp(testApp("platform")) }, "appid already hosted"},
+		{"create existing in same tenant", func() error { return t1.CreateApp(testApp("a")) }, "appid already exists"},
+		{"create within quota", func() error { return t1.CreateApp(testApp("b")) }, ""},
+		{"create over quota", func() error { return t1.CreateApp(testApp("c")) }, "quota 2 exceeded"},
+		{"update other tenant app", func() error { return t2.UpdateApp(testApp("a")) }, "appid a not found"},
+		{"delete other tenant app", func() error { return t2.DeleteApp("a") }, "appid a not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if got := t1.Appids(); len(got) != 2 || !slices.Contains(got, "a") || !slices.Contains(got, "b") {
+		t.Errorf("t1 appids = %v", got)
+	}
+	if got := t2.Appids(); len(got) != 0 {
+		t.Errorf("t2 appids = %v", got)
+	}
+}
+
+func TestTenantDelete(t *testing.T) {
+	tests := []struct {
+		name       string
+		delete     func(t *testing.T, tenant *Tenant)
+		wantAppids []string
+		wantTenant bool
+	}{
+		{"tenant delete", func(t *testing.T, tenant *Tenant) { _ = tenant.DeleteApp("a") }, []string{"b"}, true},
+		{"global delete", func(t *testing.T, tenant *Tenant) { DeleteApp("a") }, []string{"b"}, true},
+		{"delete all", func(t *testing.T, tenant *Tenant) {
+			if n := tenant.DeleteAll(); n != 2 {
+				t.Errorf("DeleteAll = %d, want 2", n)
+			}
+		}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			tenant := ForTenant("t1")
+			for _, appid := range []string{"a", "b"} {
+				if err := tenant.CreateApp(testApp(appid)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tt.delete(t, tenant)
+			if got := tenant.Appids(); strings.Join(got, ",") != strings.Join(tt.wantAppids, ",") {
+				t.Errorf("appids = %v, want %v", got, tt.wantAppids)
+			}
+			if got := slices.Contains(Tenants(), "t1"); got != tt.wantTenant {
+				t.Errorf("tenant listed = %v, want %v", got, tt.wantTenant)
+			}
+			if _, err := LoadApp("a"); err == nil {
+				t.Error("app a still hosted")
+			}
+		})
+	}
+}
+
+func TestCleanBeforeStartClearsTenants(t *testing.T) {
+	s := setupTest(t)
+	if err := ForTenant("t1").CreateApp(testApp("a")); err != nil {
+		t.Fatal(err)
+	}
+	Shutdown()
+	New(&Options{Storage: s, Logger: testLogger{t}, LazyToken: true, DisableAutoRefresh: true, AlwaysCleanBeforeStart: true})
+	if got := ForTenant("t1").Appids(); len(got) != 0 {
+		t.Errorf("appids = %v, want none", got)
+	}
+}
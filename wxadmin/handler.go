@@ -0,0 +1,248 @@
+package wxadmin
+
+import (
+	"errors"
+	"github.com/bytedance/sonic"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/utils"
+	"io"
+	"net/http"
+	"time"
+)
+
+type Options struct {
+	// 关键APP，任一没有有效token时 readiness 失败
+	Critical []string
+	// 后台刷新超过该时长未执行时 liveness 失败，默认为3倍刷新间隔
+	LivenessTimeout time.Duration
+	// 只读模式，禁用创建/更新/删除/刷新接口
+	ReadOnly bool
+	// 鉴权，作用于/apps下的全部接口；为空时不挂载/apps下的任何接口
+	Authorize func(r *http.Request) bool
+}
+
+type handler struct {
+	options *Options
+	mux     *http.ServeMux
+}
+
+// Handler
+// @Description: 管理与健康检查接口，可挂载到已有服务，如 mux.Handle("/zwx/", http.StripPrefix("/zwx", wxadmin.Handler(nil)))
+//
+//	GET    /healthz               liveness
+//	GET    /readyz                readiness
+//	GET    /domains               微信API域名健康状态
+//	GET    /apps                  全部APP健康状态
+//	GET    /apps/{appid}          APP详情，密钥脱敏
+//	POST   /apps                  创建APP
+//	PUT    /apps/{appid}          更新APP
+//	DELETE /apps/{appid}          删除APP
+//	POST   /apps/{appid}/refresh  强制刷新token
+//
+// /apps下的接口仅在设置了Authorize时挂载，写接口还要求非ReadOnly
+//
+// @param options
+// @return http.Handler
+func Handler(options *Options) http.Handler {
+	if options == nil {
+		options = new(Options)
+	}
+	h := &handler{options: options, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /healthz", h.liveness)
+	h.mux.HandleFunc("GET /readyz", h.readiness)
+	h.mux.HandleFunc("GET /domains", h.domains)
+	if options.Authorize == nil {
+		return h
+	}
+	h.mux.HandleFunc("GET /apps", h.auth(h.list))
+	h.mux.HandleFunc("GET /apps/{appid}", h.auth(h.show))
+	if !options.ReadOnly {
+		h.mux.HandleFunc("POST /apps", h.auth(h.create))
+		h.mux.HandleFunc("PUT /apps/{appid}", h.auth(h.update))
+		h.mux.HandleFunc("DELETE /apps/{appid}", h.auth(h.delete))
+		h.mux.HandleFunc("POST /apps/{appid}/refresh", h.auth(h.refresh))
+	}
+	return h
+}
+
+// auth 鉴权不通过时返回401
+func (h *handler) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.options.Authorize(r) {
+			utils.WriteError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+type probe struct {
+	Ok          bool     `json:"ok"`
+	LastRefresh int64    `json:"last_refresh,omitempty"`
+	Unhealthy   []string `json:"unhealthy,omitempty"`
+}
+
+func (h *handler) liveness(w http.ResponseWriter, _ *http.Request) {
+	timeout := h.options.LivenessTimeout
+	if timeout == 0 {
+		timeout = 3 * zwx.RefreshInterval()
+	}
+	last := zwx.LastRefresh()
+	p := &probe{Ok: utils.Now().Sub(last) < timeout, LastRefresh: last.Unix()}
+	writeProbe(w, p)
+}
+
+func (h *handler) readiness(w http.ResponseWriter, _ *http.Request) {
+	p := &probe{Ok: true}
+	for _, appid := range h.options.Critical {
+		c, err := zwx.LoadApp(appid)
+		if err != nil || !c.Status().Valid {
+			p.Ok = false
+			p.Unhealthy = append(p.Unhealthy, appid)
+		}
+	}
+	writeProbe(w, p)
+}
+
+func (h *handler) domains(w http.ResponseWriter, _ *http.Request) {
+	utils.WriteJson(w, http.StatusOK, zwx.DomainHealths())
+}
+
+func (h *handler) list(w http.ResponseWriter, _ *http.Request) {
+	utils.WriteJson(w, http.StatusOK, zwx.Statuses())
+}
+
+type appDetail struct {
+	*zwx.AppStatus
+	MainAppid      string `json:"main_appid"`
+	AgentId        string `json:"agent_id"`
+	AppSecret      string `json:"app_secret"`
+	Token          string `json:"token"`
+	EncodingAesKey string `json:"encoding_aes_key"`
+	NotifyUri      string `json:"notify_uri"`
+}
+
+func (h *handler) show(w http.ResponseWriter, r *http.Request) {
+	c, err := zwx.LoadApp(r.PathValue("appid"))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	app := c.Info()
+	utils.WriteJson(w, http.StatusOK, &appDetail{
+		AppStatus:      c.Status(),
+		MainAppid:      app.MainAppid,
+		AgentId:        app.AgentId,
+		AppSecret:      utils.Mask(app.AppSecret),
+		Token:          utils.Mask(app.Token),
+		EncodingAesKey: utils.Mask(app.EncodingAesKey),
+		NotifyUri:      app.NotifyUri,
+	})
+}
+
+func (h *handler) create(w http.ResponseWriter, r *http.Request) {
+	app, err := bindApp(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = zwx.CreateApp(*app); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.status(w, http.StatusCreated, app.Appid)
+}
+
+func (h *handler) update(w http.ResponseWriter, r *http.Request) {
+	app, err := bindApp(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	app.Appid = r.PathValue("appid")
+	if err = zwx.UpdateApp(*app); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.status(w, http.StatusOK, app.Appid)
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request) {
+	appid := r.PathValue("appid")
+	if _, err := zwx.LoadApp(appid); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	zwx.DeleteApp(appid)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) refresh(w http.ResponseWriter, r *http.Request) {
+	c, err := zwx.LoadApp(r.PathValue("appid"))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	c.ResetBreaker()
+	c.NewAccessToken()
+	h.status(w, http.StatusOK, c.Appid())
+}
+
+func (h *handler) status(w http.ResponseWriter, code int, appid string) {
+	c, err := zwx.LoadApp(appid)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, code, c.Status())
+}
+
+// appRequest 创建/更新请求体，只包含可声明字段，token等内部维护字段不可通过接口写入
+type appRequest struct {
+	AppType        zwx.AppType `json:"app_type"`
+	Appid          string      `json:"appid"`
+	AppSecret      string      `json:"app_secret"`
+	MainAppid      string      `json:"main_appid"`
+	AgentId        string      `json:"agent_id"`
+	ComponentAppid string      `json:"component_appid"`
+	Token          string      `json:"token"`
+	EncodingAesKey string      `json:"encoding_aes_key"`
+	NotifyUri      string      `json:"notify_uri"`
+}
+
+// strictJson 出现未知字段时报错
+var strictJson = sonic.Config{DisallowUnknownFields: true}.Froze()
+
+func bindApp(r *http.Request) (*zwx.App, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	req := new(appRequest)
+	if err = strictJson.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+	return &zwx.App{
+		AppType:        req.AppType,
+		Appid:          req.Appid,
+		AppSecret:      req.AppSecret,
+		MainAppid:      req.MainAppid,
+		AgentId:        req.AgentId,
+		ComponentAppid: req.ComponentAppid,
+		Token:          req.Token,
+		EncodingAesKey: req.EncodingAesKey,
+		NotifyUri:      req.NotifyUri,
+	}, nil
+}
+
+func writeProbe(w http.ResponseWriter, p *probe) {
+	code := http.StatusOK
+	if !p.Ok {
+		code = http.StatusServiceUnavailable
+	}
+	utils.WriteJson(w, code, p)
+}
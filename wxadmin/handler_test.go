@@ -0,0 +1,99 @@
+package wxadmin
+
+import (
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/internal/zwxtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKey = "admin-key"
+
+func authorize(r *http.Request) bool {
+	return r.Header.Get("X-Admin-Key") == testKey
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *Options
+		method   string
+		path     string
+		body     string
+		key      string
+		wantCode int
+		want     []string
+		notWant  []string
+	}{
+		{name: "healthz public", options: nil, method: "GET", path: "/healthz", wantCode: http.StatusOK},
+		{name: "readyz public", options: nil, method: "GET", path: "/readyz", wantCode: http.StatusOK},
+		{name: "domains public", options: nil, method: "GET", path: "/domains", wantCode: http.StatusOK},
+		{name: "apps not mounted without authorize", options: nil, method: "GET", path: "/apps", wantCode: http.StatusNotFound},
+		{name: "app detail not mounted without authorize", options: nil, method: "GET", path: "/apps/wx1", wantCode: http.StatusNotFound},
+		{name: "create not mounted without authorize", options: nil, method: "POST", path: "/apps", body: `{}`, wantCode: http.StatusNotFound},
+		{name: "list unauthorized", options: &Options{Authorize: authorize}, method: "GET", path: "/apps", wantCode: http.StatusUnauthorized},
+		{name: "list authorized", options: &Options{Authorize: authorize}, method: "GET", path: "/apps", key: testKey, wantCode: http.StatusOK, want: []string{"wx1"}},
+		{name: "detail masks secrets", options: &Options{Authorize: authorize}, method: "GET", path: "/apps/wx1", key: testKey, wantCode: http.StatusOK,
+			want: []string{`"app_secret":"secr****6789"`}, notWant: []string{"secret-0123456789"}},
+		{name: "detail not found", options: &Options{Authorize: authorize}, method: "GET", path: "/apps/nope", key: testKey, wantCode: http.StatusNotFound},
+		{name: "read only rejects create", options: &Options{Authorize: authorize, ReadOnly: true}, method: "POST", path: "/apps", key: testKey,
+			body: `{"app_type":"8","appid":"wx2","app_secret":"s"}`, wantCode: http.StatusMethodNotAllowed},
+		{name: "read only rejects delete", options: &Options{Authorize: authorize, ReadOnly: true}, method: "DELETE", path: "/apps/wx1", key: testKey, wantCode: http.StatusMethodNotAllowed},
+		{name: "create unauthorized", options: &Options{Authorize: authorize}, method: "POST", path: "/apps",
+			body: `{"app_type":"8","appid":"wx2","app_secret":"s"}`, wantCode: http.StatusUnauthorized},
+		{name: "create", options: &Options{Authorize: authorize}, method: "POST", path: "/apps", key: testKey,
+			body: `{"app_type":"8","appid":"wx2","app_secret":"s"}`, wantCode: http.StatusCreated, want: []string{"wx2"}},
+		{name: "create rejects internal fields", options: &Options{Authorize: authorize}, method: "POST", path: "/apps", key: testKey,
+			body: `{"app_type":"8","appid":"wx2","app_secret":"s","access_token":"forged"}`, wantCode: http.StatusBadRequest},
+		{name: "update rejects internal fields", options: &Options{Authorize: authorize}, method: "PUT", path: "/apps/wx1", key: testKey,
+			body: `{"app_type":"8","app_secret":"s","expire_time":"2099-01-01T00:00:00Z"}`, wantCode: http.StatusBadRequest},
+		{name: "update", options: &Options{Authorize: authorize}, method: "PUT", path: "/apps/wx1", key: testKey,
+			body: `{"app_type":"8","app_secret":"secret-new"}`, wantCode: http.StatusOK},
+		{name: "delete", options: &Options{Authorize: authorize}, method: "DELETE", path: "/apps/wx1", key: testKey, wantCode: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zwxtest.Setup(t, time.Unix(1700000000, 0), zwx.App{AppType: zwx.TypeWxVideo, Appid: "wx1", AppSecret: "secret-0123456789"})
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.key != "" {
+				r.Header.Set("X-Admin-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			Handler(tt.options).ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("body missing %q: %s", s, w.Body.String())
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(w.Body.String(), s) {
+					t.Errorf("body contains %q: %s", s, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestBindAppKeepsInternalFieldsOut(t *testing.T) {
+	zwxtest.Setup(t, time.Unix(1700000000, 0), zwx.App{AppType: zwx.TypeWxVideo, Appid: "wx1", AppSecret: "secret-0123456789"})
+	r := httptest.NewRequest("PUT", "/apps/wx1", strings.NewReader(`{"app_type":"8","app_secret":"secret-new","token":"tok"}`))
+	r.Header.Set("X-Admin-Key", testKey)
+	w := httptest.NewRecorder()
+	Handler(&Options{Authorize: authorize}).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d: %s", w.Code, w.Body.String())
+	}
+	c, err := zwx.LoadApp("wx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app := c.Info(); app.AppSecret != "secret-new" || app.Token != "tok" || app.AccessToken != "" {
+		t.Errorf("app = %+v", app)
+	}
+}
@@ -0,0 +1,98 @@
+package wxcpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"github.com/zohu/zwx/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+const (
+	testToken  = "tok"
+	testAesKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+	testAppid  = "wx1234567890"
+)
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestEncryptGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		json bool
+		msg  string
+	}{
+		{"encrypt_xml", false, "<xml><Content><![CDATA[hello]]></Content></xml>"},
+		{"encrypt_json", true, `{"Content":"hello"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utils.SetProvider(utils.NewSeededProvider(7, time.Unix(1700000000, 0)))
+			t.Cleanup(func() { utils.SetProvider(nil) })
+			cpt := NewBizMsgCrypt(testToken, testAesKey, testAppid)
+			var (
+				out     []byte
+				encrypt string
+				sign    string
+				err     error
+			)
+			if tt.json {
+				var m *BizMsgSendJson
+				if m, err = cpt.EncryptJsonMsg(tt.msg, "1700000000", "nonce"); err == nil {
+					out, err = json.MarshalIndent(m, "", "  ")
+					encrypt, sign = m.Encrypt, m.Msgsignature
+				}
+			} else {
+				var m *BizMsgSendXml
+				if m, err = cpt.EncryptXmlMsg(tt.msg, "1700000000", "nonce"); err == nil {
+					out, err = xml.MarshalIndent(m, "", "  ")
+					encrypt, sign = string(m.Encrypt), string(m.Msgsignature)
+				}
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden(t, tt.name, append(out, '\n'))
+			plain, err := cpt.DecryptMsg(sign, "1700000000", "nonce", &BizMsgRecv{Encrypt: encrypt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(plain) != tt.msg {
+				t.Errorf("round trip = %q, want %q", plain, tt.msg)
+			}
+		})
+	}
+}
+
+func TestDecryptBadSignature(t *testing.T) {
+	cpt := NewBizMsgCrypt(testToken, testAesKey, testAppid)
+	m, err := cpt.EncryptJsonMsg("{}", "1", "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cpt.DecryptMsg("bad", "1", "n", &BizMsgRecv{Encrypt: m.Encrypt}); err == nil {
+		t.Error("expected signature error")
+	}
+}
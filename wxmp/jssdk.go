@@ -0,0 +1,166 @@
+package wxmp
+
+import (
+	"errors"
+	"github.com/zohu/zwx/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type JsSdkConfig struct {
+	AppId     string `json:"appId"`
+	Timestamp int64  `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	Signature string `json:"signature"`
+}
+
+// JsSdkConfig
+// @Description: 生成wx.config所需的签名参数
+// @receiver c
+// @param pageUrl 当前网页的完整URL，#及其后面部分会被去掉
+// @return *JsSdkConfig
+// @return error
+func (c *Context) JsSdkConfig(pageUrl string) (*JsSdkConfig, error) {
+	ticket, err := c.jsTicket()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &JsSdkConfig{
+		AppId:     c.AppidMain(),
+		Timestamp: utils.Now().Unix(),
+		NonceStr:  utils.RandomStr(16),
+	}
+	cfg.Signature = utils.JsapiSignature(ticket, cfg.NonceStr, strconv.FormatInt(cfg.Timestamp, 10), pageUrl)
+	return cfg, nil
+}
+
+type CardExt struct {
+	Code      string `json:"code,omitempty"`
+	Openid    string `json:"openid,omitempty"`
+	Timestamp string `json:"timestamp"`
+	NonceStr  string `json:"nonce_str"`
+	Signature string `json:"signature"`
+}
+
+// CardExtSignature
+// @Description: 生成wx.addCard所需的cardExt，code和openid按卡券配置可为空
+// @receiver c
+// @param cardId
+// @param code
+// @param openid
+// @return *CardExt
+// @return error
+func (c *Context) CardExtSignature(cardId, code, openid string) (*CardExt, error) {
+	ticket, err := c.cardTicket()
+	if err != nil {
+		return nil, err
+	}
+	ext := &CardExt{
+		Code:      code,
+		Openid:    openid,
+		Timestamp: strconv.FormatInt(utils.Now().Unix(), 10),
+		NonceStr:  utils.RandomStr(16),
+	}
+	ext.Signature = utils.Signature(ticket, ext.Timestamp, ext.NonceStr, cardId, code, openid)
+	return ext, nil
+}
+
+type ChooseCardConfig struct {
+	ShopId    string `json:"shopId"`
+	CardType  string `json:"cardType"`
+	CardId    string `json:"cardId"`
+	Timestamp string `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	SignType  string `json:"signType"`
+	CardSign  string `json:"cardSign"`
+}
+
+// ChooseCardSignature
+// @Description: 生成wx.chooseCard所需的签名参数，参数均可为空
+// @receiver c
+// @param shopId
+// @param cardType
+// @param cardId
+// @return *ChooseCardConfig
+// @return error
+func (c *Context) ChooseCardSignature(shopId, cardType, cardId string) (*ChooseCardConfig, error) {
+	ticket, err := c.cardTicket()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &ChooseCardConfig{
+		ShopId:    shopId,
+		CardType:  cardType,
+		CardId:    cardId,
+		Timestamp: strconv.FormatInt(utils.Now().Unix(), 10),
+		NonceStr:  utils.RandomStr(16),
+		SignType:  "SHA1",
+	}
+	cfg.CardSign = utils.Signature(ticket, c.AppidMain(), shopId, cfg.Timestamp, cfg.NonceStr, cardId, cardType)
+	return cfg, nil
+}
+
+func (c *Context) jsTicket() (string, error) {
+	c.AccessToken()
+	if c.JsTicket() == "" {
+		return "", c.Error("jsapi_ticket", "ticket not ready")
+	}
+	return c.JsTicket(), nil
+}
+func (c *Context) cardTicket() (string, error) {
+	c.AccessToken()
+	if c.CardTicket() == "" {
+		return "", c.Error("card_ticket", "ticket not ready")
+	}
+	return c.CardTicket(), nil
+}
+
+// JsSdkHandler
+// @Description: 为白名单内的网页提供wx.config参数，GET ?url=当前网页URL
+// @param appid
+// @param origins 允许的来源，如 https://m.example.com
+// @return http.Handler
+func JsSdkHandler(appid string, origins ...string) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.TrimSuffix(o, "/")] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		pageUrl := r.URL.Query().Get("url")
+		u, err := url.Parse(pageUrl)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			utils.WriteError(w, http.StatusBadRequest, errors.New("invalid url"))
+			return
+		}
+		origin := u.Scheme + "://" + u.Host
+		if !allowed[origin] {
+			utils.WriteError(w, http.StatusForbidden, errors.New("origin not allowed"))
+			return
+		}
+		if o := r.Header.Get("Origin"); o != "" {
+			if !allowed[o] {
+				utils.WriteError(w, http.StatusForbidden, errors.New("origin not allowed"))
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", o)
+			w.Header().Set("Vary", "Origin")
+		}
+		c, err := App(appid)
+		if err != nil {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		cfg, err := c.JsSdkConfig(pageUrl)
+		if err != nil {
+			utils.WriteError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		utils.WriteJson(w, http.StatusOK, cfg)
+	})
+}
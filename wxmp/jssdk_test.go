@@ -0,0 +1,115 @@
+package wxmp
+
+import (
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/internal/zwxtest"
+	"github.com/zohu/zwx/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	testAppid  = "wxmp1"
+	testTicket = "sM4AOVdWfPE4DxkXGEs8VMCPGGVi4C3VM0P37wVUCFvkVAy_90u5h9nbSlYy3-Sl-HhTdfl2fzFy1AOcHKP7qg"
+)
+
+// setupTest 托管一个已有token和ticket的服务号，时钟固定为1414587457，不请求微信接口
+func setupTest(t *testing.T) {
+	t.Helper()
+	zwxtest.Setup(t, time.Unix(1414587457, 0), zwx.App{
+		AppType:     zwx.TypeWxMpServe,
+		Appid:       testAppid,
+		AppSecret:   "secret",
+		AccessToken: "token",
+		JsTicket:    testTicket,
+		CardTicket:  testTicket,
+	})
+}
+
+func TestJsSdkConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"plain", "http://mp.weixin.qq.com?params=value"},
+		{"fragment", "http://mp.weixin.qq.com?params=value#wechat_redirect"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			c, err := App(testAppid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := c.JsSdkConfig(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &JsSdkConfig{AppId: testAppid, Timestamp: 1414587457, NonceStr: cfg.NonceStr}
+			want.Signature = utils.JsapiSignature(testTicket, cfg.NonceStr, "1414587457", "http://mp.weixin.qq.com?params=value")
+			if *cfg != *want {
+				t.Errorf("config = %+v, want %+v", cfg, want)
+			}
+			if len(cfg.NonceStr) != 16 {
+				t.Errorf("nonceStr = %q", cfg.NonceStr)
+			}
+		})
+	}
+}
+
+func TestCardSignatures(t *testing.T) {
+	setupTest(t)
+	c, err := App(testAppid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext, err := c.CardExtSignature("card1", "code1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := utils.Signature(testTicket, "1414587457", ext.NonceStr, "card1", "code1", ""); ext.Signature != want || ext.Timestamp != "1414587457" {
+		t.Errorf("card ext = %+v, want signature %s", ext, want)
+	}
+	choose, err := c.ChooseCardSignature("", "GROUPON", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := utils.Signature(testTicket, testAppid, "", "1414587457", choose.NonceStr, "", "GROUPON"); choose.CardSign != want || choose.SignType != "SHA1" {
+		t.Errorf("choose card = %+v, want signature %s", choose, want)
+	}
+}
+
+func TestJsSdkHandler(t *testing.T) {
+	setupTest(t)
+	h := JsSdkHandler(testAppid, "https://m.example.com/")
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		origin string
+		want   int
+	}{
+		{"ok", http.MethodGet, "https://m.example.com/p?x=1#top", "", http.StatusOK},
+		{"ok with origin", http.MethodGet, "https://m.example.com/p", "https://m.example.com", http.StatusOK},
+		{"post", http.MethodPost, "https://m.example.com/p", "", http.StatusMethodNotAllowed},
+		{"relative url", http.MethodGet, "/p", "", http.StatusBadRequest},
+		{"other page", http.MethodGet, "https://evil.com/p", "", http.StatusForbidden},
+		{"other origin", http.MethodGet, "https://m.example.com/p", "https://evil.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/jssdk?url="+url.QueryEscape(tt.url), nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
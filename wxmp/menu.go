@@ -58,18 +58,19 @@ type MenuDiy struct {
 func (c *Context) MenuAdd(menu *Menu) error {
 	var resp zwx.WxResponse
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiCgiBin.WithPath("menu/create")).
-		SetAccessToken(c.AccessToken()).
+		Idempotent(true).
+		SetAccessTokenFrom(c.Context).
 		SetJson(menu).
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return c.Error("menu add", err.Error())
+		return c.ErrorWith("menu add", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.MenuAdd(menu)
 		}
-		return c.Error("menu add", resp.Errmsg)
+		return c.ErrorFrom("menu add", resp)
 	}
 	return nil
 }
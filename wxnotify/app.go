@@ -1,7 +1,10 @@
 package wxnotify
 
 import (
+	"bytes"
 	"encoding/xml"
+	"errors"
+	"github.com/bytedance/sonic"
 	"github.com/zohu/zwx"
 	"github.com/zohu/zwx/wxcpt"
 )
@@ -15,23 +18,100 @@ func App(appid string) (*Context, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !c.IsWxMpServe() && !c.IsWxMpSubscribe() && !c.IsWork() && !c.IsWxMiniProgram() {
-		return nil, c.Error("", "推送消息仅支持服务号、订阅号、企业号、小程序")
+	if !c.IsWxMpServe() && !c.IsWxMpSubscribe() && !c.IsWork() && !c.IsWxMiniProgram() && !c.IsWxOpen() {
+		return nil, c.Error("", "推送消息仅支持服务号、订阅号、企业号、小程序、开放平台")
 	}
 	return &Context{Context: c}, nil
 }
 
+// crypt 第三方平台代授权的APP使用第三方平台的appid加解密，企业微信为corpid
+func (c *Context) crypt() *wxcpt.BizMsgCrypt {
+	receiverId := c.AppidMain()
+	if c.ComponentAppid() != "" {
+		receiverId = c.ComponentAppid()
+	}
+	token, aesKey := c.notifyKeys()
+	return wxcpt.NewBizMsgCrypt(token, aesKey, receiverId)
+}
+
+// notifyKeys 第三方平台代授权的APP推送至第三方平台，使用第三方平台当前的token和EncodingAesKey
+func (c *Context) notifyKeys() (string, string) {
+	if c.ComponentAppid() != "" {
+		if component, err := zwx.LoadApp(c.ComponentAppid()); err == nil {
+			return component.NotifyToken(), component.NotifyEncodingAesKey()
+		}
+	}
+	return c.NotifyToken(), c.NotifyEncodingAesKey()
+}
+
+// Decode
+// @Description: 按推送模式解析消息，企业微信、开放平台以及encrypt_type=aes的安全/兼容模式解密Encrypt字段，否则为明文模式，校验signature后直接解析。
+// 消息体为JSON时按小程序JSON格式解析，被动回复也使用JSON
+// @receiver c
+// @param p
+// @param body
+// @return *Message
+// @return error
+func (c *Context) Decode(p *ReqNotify, body []byte) (*Message, error) {
+	isJson := isJsonBody(body)
+	if c.IsWork() || c.IsWxOpen() || p.EncryptType == EncryptTypeAes {
+		recv := new(wxcpt.BizMsgRecv)
+		if err := unmarshal(isJson, body, recv); err != nil {
+			return nil, err
+		}
+		cpt := c.crypt()
+		data, err := cpt.DecryptMsg(p.MsgSignature, p.Timestamp, p.Nonce, recv)
+		if err != nil {
+			return nil, err
+		}
+		return c.parse(p, data, isJson, true)
+	}
+	if !c.CheckSignature(p) {
+		return nil, errors.New("signature not equal")
+	}
+	return c.parse(p, body, isJson, false)
+}
+
 func (c *Context) DecodeMessage(p *ReqNotify, recv *wxcpt.BizMsgRecv) (*Message, error) {
-	cpt := wxcpt.NewBizMsgCrypt(c.NotifyToken(), c.NotifyEncodingAesKey(), c.AppidMain())
+	cpt := c.crypt()
 	if cptByte, err := cpt.DecryptMsg(p.MsgSignature, p.Timestamp, p.Nonce, recv); err != nil {
 		return nil, err
 	} else {
-		msg := new(Message)
-		msg.Nonce = p.Nonce
-		msg.ctx = c
-		if err = xml.Unmarshal(cptByte, msg); err != nil {
+		return c.parse(p, cptByte, false, true)
+	}
+}
+
+func (c *Context) parse(p *ReqNotify, data []byte, isJson, encrypted bool) (*Message, error) {
+	msg := new(Message)
+	msg.Nonce = p.Nonce
+	msg.ctx = c
+	msg.encrypted = encrypted
+	msg.json = isJson
+	msg.raw = data
+	if err := unmarshal(isJson, data, msg); err != nil {
+		return nil, err
+	}
+	if isJson {
+		if err := msg.parseJsonList(data); err != nil {
 			return nil, err
 		}
-		return msg, nil
 	}
+	if msg.InfoType != "" && msg.MsgType == "" {
+		msg.MsgType = MessageTypeEvent
+		msg.Event = msg.InfoType
+	}
+	return msg, nil
+}
+
+func unmarshal(isJson bool, data []byte, v any) error {
+	if isJson {
+		return sonic.Unmarshal(data, v)
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// isJsonBody 小程序可在后台将消息推送配置为JSON格式
+func isJsonBody(body []byte) bool {
+	b := bytes.TrimSpace(body)
+	return len(b) > 0 && b[0] == '{'
 }
@@ -0,0 +1,187 @@
+package wxnotify
+
+import (
+	"errors"
+	"github.com/zohu/zwx/utils"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DefaultAsyncDeadline 异步模式下等待处理结果的时长，需小于微信的5秒超时
+const DefaultAsyncDeadline = 4 * time.Second
+
+// CustomServiceWindow 公众号、小程序客服消息只能在用户互动后48小时内发送
+const CustomServiceWindow = 48 * time.Hour
+
+// asyncQueueFactor 每个worker的排队容量
+const asyncQueueFactor = 64
+
+type asyncPool struct {
+	workers  int
+	deadline time.Duration
+	jobs     chan *asyncJob
+	once     sync.Once
+	wg       sync.WaitGroup
+	mu       sync.RWMutex // 保护closed与jobs的关闭
+	closed   bool
+}
+
+type asyncJob struct {
+	msg     *Message
+	task    func() // 后台任务，不为空时msg与done不使用
+	done    chan Reply
+	mu      sync.Mutex
+	expired bool
+}
+
+// Async
+// @Description: 异步处理模式，消息交由worker池处理，deadline内完成则被动回复，超时先应答微信，处理完成后通过客服消息发送回复
+// @receiver s
+// @param workers worker数量
+// @param deadline 等待处理结果的时长，0表示立即应答，所有回复都通过客服消息发送
+// @return *Server
+func (s *Server) Async(workers int, deadline time.Duration) *Server {
+	if workers <= 0 {
+		workers = 1
+	}
+	s.async = &asyncPool{
+		workers:  workers,
+		deadline: deadline,
+		jobs:     make(chan *asyncJob, workers*asyncQueueFactor),
+	}
+	return s
+}
+
+// Close
+// @Description: 停止异步worker池，之后的消息返回503由微信重试，等待已排队的消息处理完成后返回；未开启异步模式时无操作，
+// 可挂载到服务的关闭流程，如 httpServer.RegisterOnShutdown(server.Close)
+// @receiver s
+func (s *Server) Close() {
+	if s.async == nil {
+		return
+	}
+	s.async.mu.Lock()
+	if s.async.closed {
+		s.async.mu.Unlock()
+		return
+	}
+	s.async.closed = true
+	close(s.async.jobs)
+	s.async.mu.Unlock()
+	s.async.wg.Wait()
+}
+
+func (s *Server) dispatchAsync(c *Context, msg *Message) *response {
+	job := &asyncJob{msg: msg, done: make(chan Reply, 1)}
+	if err := s.enqueue(job); err != nil {
+		c.Logger().Errorf("[%s] notify %v, drop %s", c.Appid(), err, msg.DedupKey())
+		return textResponse(http.StatusServiceUnavailable, "busy")
+	}
+	timer := time.NewTimer(s.async.deadline)
+	defer timer.Stop()
+	select {
+	case reply := <-job.done:
+		return s.render(c, msg, reply)
+	case <-timer.C:
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	select {
+	case reply := <-job.done:
+		return s.render(c, msg, reply)
+	default:
+		job.expired = true
+		return c.noReply()
+	}
+}
+
+// enqueue 投递任务，按需启动worker
+func (s *Server) enqueue(job *asyncJob) error {
+	s.async.mu.RLock()
+	defer s.async.mu.RUnlock()
+	if s.async.closed {
+		return errors.New("server closed")
+	}
+	s.async.once.Do(func() {
+		s.async.wg.Add(s.async.workers)
+		for i := 0; i < s.async.workers; i++ {
+			go s.worker()
+		}
+	})
+	select {
+	case s.async.jobs <- job:
+		return nil
+	default:
+		return errors.New("queue full")
+	}
+}
+
+func (s *Server) worker() {
+	defer s.async.wg.Done()
+	for job := range s.async.jobs {
+		if job.task != nil {
+			job.task()
+			continue
+		}
+		reply := s.call(job.msg)
+		job.mu.Lock()
+		expired := job.expired
+		if !expired {
+			job.done <- reply
+		}
+		job.mu.Unlock()
+		if expired && !isNilReply(reply) {
+			s.sendLate(job.msg, reply)
+		}
+	}
+}
+
+// sendLate 超时后通过客服消息发送回复，panic不能影响worker
+func (s *Server) sendLate(msg *Message, reply Reply) {
+	defer func() {
+		if err := recover(); err != nil {
+			msg.logger().Errorf("[%s] send late reply panic: %v\n%s", msg.appid(), err, debug.Stack())
+		}
+	}()
+	c := msg.ctx
+	if !c.IsWork() && utils.Now().Sub(time.Unix(msg.CreateTime, 0)) > CustomServiceWindow {
+		c.Logger().Errorf("[%s] drop late reply to %s, out of customer service window", c.Appid(), msg.FromUserName)
+		return
+	}
+	if err := c.SendCustom(reply); err != nil {
+		c.Logger().Errorf("[%s] send late reply failed: %s", c.Appid(), err.Error())
+	}
+}
+
+// call worker中执行处理函数，panic不能影响其他任务
+func (s *Server) call(msg *Message) (reply Reply) {
+	defer func() {
+		if err := recover(); err != nil {
+			msg.logger().Errorf("[%s] notify handler panic: %v\n%s", msg.appid(), err, debug.Stack())
+			reply = nil
+		}
+	}()
+	return s.dispatcher(msg)
+}
+
+// background 在消息所属服务的worker池中执行耗时任务，如托管授权方、全量同步通讯录；
+// 服务未开启异步模式或消息未经服务接收时在当前协程同步执行
+func (msg *Message) background(task func()) error {
+	if msg.server == nil || msg.server.async == nil {
+		msg.run(task)
+		return nil
+	}
+	return msg.server.enqueue(&asyncJob{task: func() { msg.run(task) }})
+}
+
+// run 执行后台任务，panic不能影响worker
+func (msg *Message) run(task func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			msg.logger().Errorf("[%s] background task panic: %v\n%s", msg.appid(), err, debug.Stack())
+		}
+	}()
+	task()
+}
@@ -0,0 +1,108 @@
+package wxnotify
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// asyncText CreateTime远早于测试时钟，超时回复会因超出客服消息窗口被丢弃，不请求微信接口
+func asyncText(id int) string {
+	return fmt.Sprintf(`<xml><ToUserName>gh_1</ToUserName><FromUserName>u1</FromUserName><CreateTime>100</CreateTime><MsgType>text</MsgType><Content>%d</Content><MsgId>%d</MsgId></xml>`, id, id)
+}
+
+func TestAsyncReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Duration
+		handler  func(msg *Message) Reply
+		wantBody string
+	}{
+		{"reply within deadline", time.Second, func(msg *Message) Reply { return msg.ReplyText("fast") }, "fast"},
+		{"late reply answers success", 10 * time.Millisecond, func(msg *Message) Reply {
+			time.Sleep(100 * time.Millisecond)
+			return msg.ReplyText("slow")
+		}, "success"},
+		{"zero deadline answers immediately", 0, func(msg *Message) Reply { return msg.ReplyText("any") }, "success"},
+		{"panic answers success", time.Second, func(msg *Message) Reply { panic("boom") }, "success"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			done := make(chan struct{}, 2)
+			s := NewServer("wxmp", func(msg *Message) Reply {
+				defer func() { done <- struct{}{} }()
+				return tt.handler(msg)
+			}).Dedup(0).Async(1, tt.deadline)
+			t.Cleanup(s.Close)
+			w := postPlain(s, asyncText(1))
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Fatalf("response = %d %s, want %q", w.Code, w.Body.String(), tt.wantBody)
+			}
+			<-done
+			// worker在panic或超时后仍可处理后续消息
+			if w = postPlain(s, asyncText(2)); w.Code != http.StatusOK {
+				t.Errorf("next response = %d %s", w.Code, w.Body.String())
+			}
+			<-done
+		})
+	}
+}
+
+func TestAsyncOverflow(t *testing.T) {
+	setupTest(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	s := NewServer("wxmp", func(msg *Message) Reply {
+		once.Do(func() { close(started) })
+		<-release
+		return nil
+	}).Dedup(0).Async(1, 0)
+	if w := postPlain(s, asyncText(0)); w.Code != http.StatusOK {
+		t.Fatalf("first response = %d", w.Code)
+	}
+	<-started
+	for i := 1; i <= asyncQueueFactor; i++ {
+		if w := postPlain(s, asyncText(i)); w.Code != http.StatusOK {
+			t.Fatalf("queued %d response = %d", i, w.Code)
+		}
+	}
+	if w := postPlain(s, asyncText(asyncQueueFactor+1)); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("overflow response = %d, want 503", w.Code)
+	}
+	close(release)
+	s.Close()
+}
+
+func TestAsyncOrderAndClose(t *testing.T) {
+	setupTest(t)
+	var mu sync.Mutex
+	var got []string
+	s := NewServer("wxmp", func(msg *Message) Reply {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got = append(got, msg.Content)
+		mu.Unlock()
+		return nil
+	}).Dedup(0).Async(1, 0)
+	var want []string
+	for i := 0; i < 10; i++ {
+		if w := postPlain(s, asyncText(i)); w.Code != http.StatusOK {
+			t.Fatalf("response %d = %d", i, w.Code)
+		}
+		want = append(want, strconv.Itoa(i))
+	}
+	// Close等待排队的消息全部处理完成
+	s.Close()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+	if w := postPlain(s, asyncText(10)); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("after close response = %d, want 503", w.Code)
+	}
+	s.Close()
+}
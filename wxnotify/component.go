@@ -0,0 +1,101 @@
+package wxnotify
+
+import (
+	"github.com/valyala/fasthttp"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/wxopen"
+	"net/http"
+)
+
+// ComponentHooks
+// @Description: 第三方平台授权变更回调，均可为空
+type ComponentHooks struct {
+	// 授权成功、更新授权，授权方已托管
+	Authorized func(msg *Message, app *zwx.App)
+	// 取消授权，授权方已停止托管
+	Unauthorized func(msg *Message, authorizerAppid string)
+	// 其他通知，如快速注册审核
+	Default HandlerFunc
+}
+
+// ComponentHandler
+// @Description: 开放平台授权事件接收处理器，如 mux.Handle("/wx/component", wxnotify.ComponentHandler(componentAppid, hooks))
+// @param appid 第三方平台appid
+// @param hooks
+// @return http.Handler
+func ComponentHandler(appid string, hooks *ComponentHooks) http.Handler {
+	return NewServer(appid, ComponentDispatcher(hooks)).Async(componentWorkers, DefaultAsyncDeadline)
+}
+
+// ComponentFastHandler
+// @Description: fasthttp开放平台授权事件接收处理器
+// @param appid 第三方平台appid
+// @param hooks
+// @return fasthttp.RequestHandler
+func ComponentFastHandler(appid string, hooks *ComponentHooks) fasthttp.RequestHandler {
+	return NewServer(appid, ComponentDispatcher(hooks)).Async(componentWorkers, DefaultAsyncDeadline).ServeFastHTTP
+}
+
+// componentWorkers 开放平台通知处理的worker数量，托管授权方在worker中执行
+const componentWorkers = 4
+
+// ComponentDispatcher
+// @Description: 保存component_verify_ticket，授权、更新授权时在回调服务的worker池中托管授权方，取消授权时停止托管，再调用hooks；
+// 托管失败时不记录为已处理，微信重试推送时重新托管
+// @param hooks
+// @return Dispatcher
+func ComponentDispatcher(hooks *ComponentHooks) Dispatcher {
+	if hooks == nil {
+		hooks = new(ComponentHooks)
+	}
+	return func(msg *Message) Reply {
+		if !msg.ctx.IsWxOpen() {
+			msg.ctx.Logger().Errorf("[%s] component notify requires an open platform app", msg.ctx.Appid())
+			return nil
+		}
+		c := &wxopen.Context{Context: msg.ctx.Context}
+		switch msg.InfoType {
+		case MessageEventVerifyTicket:
+			c.SetVerifyTicket(msg.ComponentVerifyTicket)
+		case MessageEventAuthorized, MessageEventUpdateAuthorized:
+			// 托管需要多次请求微信接口，在worker池中执行以便立即返回success
+			if err := msg.background(func() { authorize(c, msg, hooks) }); err != nil {
+				c.Logger().Errorf("[%s] schedule host authorizer %s failed: %s", c.Appid(), msg.AuthorizerAppid, err.Error())
+				msg.releaseDedup()
+			}
+		case MessageEventUnauthorized:
+			if err := c.Unauthorize(msg.AuthorizerAppid); err != nil {
+				c.Logger().Errorf("[%s] release authorizer %s failed: %s", c.Appid(), msg.AuthorizerAppid, err.Error())
+				return nil
+			}
+			if hooks.Unauthorized != nil {
+				hooks.Unauthorized(msg, msg.AuthorizerAppid)
+			}
+		default:
+			if hooks.Default != nil {
+				hooks.Default(msg)
+			}
+		}
+		// 开放平台通知只需返回success
+		return nil
+	}
+}
+
+// authorize 托管授权方并调用hooks，托管失败(含panic)时释放去重标记以便微信重试
+func authorize(c *wxopen.Context, msg *Message, hooks *ComponentHooks) {
+	hosted := false
+	defer func() {
+		if !hosted {
+			msg.releaseDedup()
+		}
+	}()
+	app, err := c.Authorize(msg.AuthorizationCode)
+	if err != nil {
+		c.Logger().Errorf("[%s] host authorizer %s failed: %s", c.Appid(), msg.AuthorizerAppid, err.Error())
+		return
+	}
+	hosted = true
+	if hooks.Authorized != nil {
+		hooks.Authorized(msg, app)
+	}
+}
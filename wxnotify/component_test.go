@@ -0,0 +1,170 @@
+package wxnotify
+
+import (
+	"fmt"
+	"github.com/bytedance/sonic"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/internal/zwxtest"
+	"github.com/zohu/zwx/utils"
+	"github.com/zohu/zwx/wxcpt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOpenApi 模拟开放平台接口，授权码bad返回授权码过期
+type fakeOpenApi struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (a *fakeOpenApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/cgi-bin/")
+	a.mu.Lock()
+	a.calls[path]++
+	a.mu.Unlock()
+	var req map[string]string
+	body, _ := io.ReadAll(r.Body)
+	_ = sonic.Unmarshal(body, &req)
+	switch path {
+	case "component/api_query_auth":
+		code := req["authorization_code"]
+		if code == "bad" {
+			_, _ = w.Write([]byte(`{"errcode":61010,"errmsg":"code is expired"}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"authorization_info":{"authorizer_appid":"wxa1","authorizer_access_token":"token-%s","expires_in":7200,"authorizer_refresh_token":"refresh-%s"}}`, code, code)
+	case "component/api_get_authorizer_info":
+		_, _ = w.Write([]byte(`{"authorizer_info":{"service_type_info":{"id":2}}}`))
+	case "ticket/getticket":
+		_, _ = w.Write([]byte(`{"errcode":0,"ticket":"ticket","expires_in":7200}`))
+	default:
+		_, _ = w.Write([]byte(`{"errcode":0}`))
+	}
+}
+
+func (a *fakeOpenApi) count(path string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls[path]
+}
+
+func componentNotify(createTime int, infoType MessageEvent, extra string) string {
+	return fmt.Sprintf(`<xml><AppId>wxopen1</AppId><CreateTime>%d</CreateTime><InfoType>%s</InfoType><AuthorizerAppid>wxa1</AuthorizerAppid>%s</xml>`, createTime, infoType, extra)
+}
+
+// postEncrypted 开放平台通知均为安全模式
+func postEncrypted(t *testing.T, s *Server, appid, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	send, err := wxcpt.NewBizMsgCrypt(testToken, testAesKey, appid).EncryptXmlMsg(body, "1700000000", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := url.Values{}
+	q.Set("timestamp", "1700000000")
+	q.Set("nonce", "nonce")
+	q.Set("msg_signature", string(send.Msgsignature))
+	q.Set("encrypt_type", EncryptTypeAes)
+	recv := fmt.Sprintf(`<xml><ToUserName>%s</ToUserName><Encrypt>%s</Encrypt></xml>`, appid, send.Encrypt)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notify?"+q.Encode(), strings.NewReader(recv)))
+	return w
+}
+
+func TestComponentDispatcher(t *testing.T) {
+	api := &fakeOpenApi{calls: make(map[string]int)}
+	zwxtest.SetupOptions(t, &zwx.Options{
+		Provider:   utils.NewSeededProvider(1, time.Unix(testNow, 0)),
+		HttpClient: zwxtest.MockApi(t, api),
+	},
+		zwx.App{AppType: zwx.TypeWxOpen, Appid: "wxopen1", AppSecret: "s", Token: testToken, EncodingAesKey: testAesKey, AccessToken: "component-token"},
+		zwx.App{AppType: zwx.TypeWxMpServe, Appid: "wxmp", AppSecret: "s", Token: testToken, EncodingAesKey: testAesKey},
+	)
+	var hooked []string
+	hooks := &ComponentHooks{
+		Authorized:   func(msg *Message, app *zwx.App) { hooked = append(hooked, "authorized "+app.Appid) },
+		Unauthorized: func(msg *Message, appid string) { hooked = append(hooked, "unauthorized "+appid) },
+	}
+	// post 每次使用新的服务，Close等待后台托管完成
+	post := func(t *testing.T, appid, body string) {
+		s := NewServer(appid, ComponentDispatcher(hooks)).Async(1, DefaultAsyncDeadline)
+		w := postEncrypted(t, s, appid, body)
+		s.Close()
+		if w.Code != http.StatusOK {
+			t.Fatalf("response = %d %s", w.Code, w.Body.String())
+		}
+	}
+	hosted := func() *zwx.App {
+		c, err := zwx.LoadApp("wxa1")
+		if err != nil {
+			return nil
+		}
+		app := c.Info()
+		return &app
+	}
+	tests := []struct {
+		name      string
+		appid     string
+		body      string
+		check     func(t *testing.T)
+		wantQuery int // api_query_auth累计调用次数
+	}{
+		{"verify ticket", "wxopen1", componentNotify(1, MessageEventVerifyTicket, "<ComponentVerifyTicket>ticket@1</ComponentVerifyTicket>"), func(t *testing.T) {
+			c, _ := zwx.LoadApp("wxopen1")
+			if c.VerifyTicket() != "ticket@1" {
+				t.Errorf("verify ticket = %q", c.VerifyTicket())
+			}
+		}, 0},
+		{"non open app ignored", "wxmp", componentNotify(2, MessageEventAuthorized, "<AuthorizationCode>c1</AuthorizationCode>"), func(t *testing.T) {
+			if hosted() != nil {
+				t.Error("hosted by non open app")
+			}
+		}, 0},
+		{"hosting failure", "wxopen1", componentNotify(3, MessageEventAuthorized, "<AuthorizationCode>bad</AuthorizationCode>"), func(t *testing.T) {
+			if hosted() != nil {
+				t.Error("hosted with bad code")
+			}
+		}, 1},
+		{"failed notify retried", "wxopen1", componentNotify(3, MessageEventAuthorized, "<AuthorizationCode>bad</AuthorizationCode>"), nil, 2},
+		{"authorized", "wxopen1", componentNotify(4, MessageEventAuthorized, "<AuthorizationCode>c1</AuthorizationCode>"), func(t *testing.T) {
+			app := hosted()
+			if app == nil || app.AppType != zwx.TypeWxMpServe || app.ComponentAppid != "wxopen1" || app.AppSecret != "refresh-c1" || app.AccessToken != "token-c1" {
+				t.Errorf("hosted = %+v", app)
+			}
+		}, 3},
+		{"duplicate authorized skipped", "wxopen1", componentNotify(4, MessageEventAuthorized, "<AuthorizationCode>c1</AuthorizationCode>"), nil, 3},
+		{"update authorized keeps returned token", "wxopen1", componentNotify(5, MessageEventUpdateAuthorized, "<AuthorizationCode>c2</AuthorizationCode>"), func(t *testing.T) {
+			if app := hosted(); app == nil || app.AppSecret != "refresh-c2" || app.AccessToken != "token-c2" {
+				t.Errorf("hosted = %+v", app)
+			}
+			if n := api.count("component/api_authorizer_token"); n != 0 {
+				t.Errorf("authorizer token refreshed %d times", n)
+			}
+		}, 4},
+		{"unauthorized", "wxopen1", componentNotify(6, MessageEventUnauthorized, ""), func(t *testing.T) {
+			if hosted() != nil {
+				t.Error("still hosted")
+			}
+		}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post(t, tt.appid, tt.body)
+			if tt.check != nil {
+				tt.check(t)
+			}
+			if n := api.count("component/api_query_auth"); n != tt.wantQuery {
+				t.Errorf("query auth calls = %d, want %d", n, tt.wantQuery)
+			}
+		})
+	}
+	want := "authorized wxa1,authorized wxa1,unauthorized wxa1"
+	if got := strings.Join(hooked, ","); got != want {
+		t.Errorf("hooks = %s, want %s", got, want)
+	}
+}
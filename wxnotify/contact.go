@@ -0,0 +1,315 @@
+package wxnotify
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/bytedance/sonic"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/wxwork"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ContactChangeType string
+
+// 通讯录变更类型
+const (
+	ContactCreateUser  ContactChangeType = "create_user"
+	ContactUpdateUser  ContactChangeType = "update_user"
+	ContactDeleteUser  ContactChangeType = "delete_user"
+	ContactCreateParty ContactChangeType = "create_party"
+	ContactUpdateParty ContactChangeType = "update_party"
+	ContactDeleteParty ContactChangeType = "delete_party"
+	ContactUpdateTag   ContactChangeType = "update_tag"
+)
+
+// ErrNeedResync DirectoryStore发现本地数据与变更不一致(如更新不存在的成员)时返回，触发全量同步
+var ErrNeedResync = errors.New("directory out of sync")
+
+// ContactChange
+// @Description: 通讯录变更记录，update_*事件只推送变更的字段，存储需按Fields合并
+type ContactChange struct {
+	Corpid     string
+	ChangeType ContactChangeType
+	Time       time.Time
+	// 成员变更，Userid为变更前的userid
+	User *wxwork.User
+	// update_user修改了userid时的新userid
+	NewUserid string
+	// 部门变更
+	Party *wxwork.Department
+	// 标签成员变更
+	Tag *ContactTagChange
+	// 事件中出现的字段名，如Mobile、Department
+	Fields []string
+}
+
+// HasField 事件中是否包含该字段
+func (c *ContactChange) HasField(name string) bool {
+	for _, f := range c.Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+type ContactTagChange struct {
+	Tagid       int64
+	AddUserids  []string
+	DelUserids  []string
+	AddPartyids []int64
+	DelPartyids []int64
+}
+
+// DirectorySnapshot
+// @Description: 全量通讯录
+type DirectorySnapshot struct {
+	Departments []wxwork.Department
+	Users       []wxwork.User
+	Tags        []DirectoryTag
+}
+type DirectoryTag struct {
+	wxwork.Tag
+	Userids  []string
+	Partyids []int64
+}
+
+// DirectoryStore
+// @Description: 通讯录存储，由业务实现
+type DirectoryStore interface {
+	// Apply 应用增量变更，数据不一致时返回ErrNeedResync
+	Apply(change *ContactChange) error
+	// Replace 全量同步，替换corpid下的全部数据
+	Replace(corpid string, snapshot *DirectorySnapshot) error
+}
+
+// ContactSync
+// @Description: 企业微信通讯录变更同步，如 router.OnEvent(wxnotify.MessageWorkTypeChangeContact, sync.Handle)
+type ContactSync struct {
+	store DirectoryStore
+}
+
+// NewContactSync
+// @Description: 创建通讯录同步
+// @param store
+// @return *ContactSync
+func NewContactSync(store DirectoryStore) *ContactSync {
+	return &ContactSync{store: store}
+}
+
+// Handle
+// @Description: 处理change_contact事件，可直接注册为路由处理函数，不被动回复；
+// 数据不一致时在回调服务的worker池中全量同步，建议回调服务开启Async
+// @receiver s
+// @param msg
+// @return Reply
+func (s *ContactSync) Handle(msg *Message) Reply {
+	if err := s.Apply(msg); err != nil {
+		msg.ctx.Logger().Errorf("[%s] apply contact change failed: %s", msg.ctx.Appid(), err.Error())
+		if errors.Is(err, ErrNeedResync) {
+			appid := msg.ctx.Appid()
+			if err = msg.background(func() { s.resyncOnce(appid) }); err != nil {
+				msg.ctx.Logger().Errorf("[%s] schedule contact resync failed: %s", appid, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Apply
+// @Description: 解析并应用通讯录变更
+// @receiver s
+// @param msg
+// @return error
+func (s *ContactSync) Apply(msg *Message) error {
+	change, err := ParseContactChange(msg)
+	if err != nil {
+		return err
+	}
+	return s.store.Apply(change)
+}
+
+// resyncOnce 多实例下10分钟内只执行一次全量同步，成功后锁不释放，等待过期，避免连续的不一致事件反复触发全量拉取；
+// 失败时释放锁，下一次不一致事件可立即重试
+func (s *ContactSync) resyncOnce(appid string) {
+	c, err := App(appid)
+	if err != nil {
+		return
+	}
+	key := zwx.PrefixNotify.Key(appid, "contact_resync")
+	if !c.Storage().SetNX(key, "1", 10*time.Minute) {
+		return
+	}
+	if err = s.Resync(appid); err != nil {
+		c.Storage().Del(key)
+		c.Logger().Errorf("[%s] contact resync failed: %s", appid, err.Error())
+	}
+}
+
+// Resync
+// @Description: 拉取全量部门、成员、标签并替换存储，用于首次初始化或丢失事件后的修复
+// @receiver s
+// @param appid 具备通讯录读取权限的企业微信应用
+// @return error
+func (s *ContactSync) Resync(appid string) error {
+	c, err := wxwork.App(appid)
+	if err != nil {
+		return err
+	}
+	snapshot := new(DirectorySnapshot)
+	if snapshot.Departments, err = c.DepartmentList(0); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, d := range snapshot.Departments {
+		users, err := c.UserList(d.Id)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			if !seen[u.Userid] {
+				seen[u.Userid] = true
+				snapshot.Users = append(snapshot.Users, u)
+			}
+		}
+	}
+	tags, err := c.TagList()
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		members, err := c.TagUsers(t.Tagid)
+		if err != nil {
+			return err
+		}
+		tag := DirectoryTag{Tag: t, Partyids: members.Partylist}
+		for _, u := range members.Userlist {
+			tag.Userids = append(tag.Userids, u.Userid)
+		}
+		snapshot.Tags = append(snapshot.Tags, tag)
+	}
+	return s.store.Replace(c.AppidMain(), snapshot)
+}
+
+// ParseContactChange
+// @Description: 将change_contact事件转换为变更记录
+// @param msg
+// @return *ContactChange
+// @return error
+func ParseContactChange(msg *Message) (*ContactChange, error) {
+	e, ok := msg.Typed().(*ChangeContactEvent)
+	if !ok {
+		return nil, errors.New("not a change_contact event: " + string(msg.Event))
+	}
+	change := &ContactChange{
+		Corpid:     msg.ctx.AppidMain(),
+		ChangeType: ContactChangeType(e.ChangeType),
+		Time:       time.Unix(e.CreateTime, 0),
+		Fields:     eventFields(msg),
+	}
+	switch change.ChangeType {
+	case ContactCreateUser, ContactUpdateUser, ContactDeleteUser:
+		change.NewUserid = e.NewUserID
+		change.User = &wxwork.User{
+			Userid:         e.UserID,
+			Name:           e.Name,
+			Department:     splitInt64s(e.Department),
+			Position:       e.Position,
+			Mobile:         e.Mobile,
+			Email:          e.Email,
+			BizMail:        e.BizMail,
+			IsLeaderInDept: splitInts(e.IsLeaderInDept),
+			DirectLeader:   splitStrings(e.DirectLeader),
+			Avatar:         e.Avatar,
+			Telephone:      e.Telephone,
+			Alias:          e.Alias,
+			Address:        e.Address,
+			Status:         int(e.Status),
+		}
+		if e.Gender != 0 {
+			change.User.Gender = strconv.FormatInt(e.Gender, 10)
+		}
+		change.User.MainDepartment, _ = strconv.ParseInt(e.MainDepartment, 10, 64)
+	case ContactCreateParty, ContactUpdateParty, ContactDeleteParty:
+		change.Party = &wxwork.Department{
+			Id:       e.Id,
+			Name:     e.Name,
+			Parentid: e.ParentId,
+			Order:    e.Order,
+		}
+	case ContactUpdateTag:
+		change.Tag = &ContactTagChange{
+			Tagid:       e.TagId,
+			AddUserids:  splitStrings(e.AddUserItems),
+			DelUserids:  splitStrings(e.DelUserItems),
+			AddPartyids: splitInt64s(e.AddPartyItems),
+			DelPartyids: splitInt64s(e.DelPartyItems),
+		}
+	default:
+		return nil, errors.New("unknown contact change type: " + e.ChangeType)
+	}
+	return change, nil
+}
+
+// eventCommonFields 不属于变更内容的字段
+var eventCommonFields = map[string]bool{
+	"ToUserName": true, "FromUserName": true, "CreateTime": true, "MsgType": true, "Event": true, "ChangeType": true, "AgentID": true,
+}
+
+// eventFields 事件中出现的一级字段名
+func eventFields(msg *Message) []string {
+	var fields []string
+	if msg.IsJson() {
+		m := make(map[string]any)
+		_ = sonic.Unmarshal(msg.raw, &m)
+		for k := range m {
+			if !eventCommonFields[k] {
+				fields = append(fields, k)
+			}
+		}
+		return fields
+	}
+	d := xml.NewDecoder(bytes.NewReader(msg.raw))
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return fields
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && !eventCommonFields[t.Name.Local] {
+				fields = append(fields, t.Name.Local)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+func splitStrings(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+func splitInt64s(s string) []int64 {
+	var r []int64
+	for _, v := range splitStrings(s) {
+		if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			r = append(r, n)
+		}
+	}
+	return r
+}
+func splitInts(s string) []int {
+	var r []int
+	for _, v := range splitInt64s(s) {
+		r = append(r, int(v))
+	}
+	return r
+}
@@ -0,0 +1,148 @@
+package wxnotify
+
+import (
+	"errors"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/wxwork"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseContactChange(t *testing.T) {
+	setupTest(t)
+	const head = `<xml><ToUserName>corp1</ToUserName><FromUserName>sys</FromUserName><CreateTime>1700000000</CreateTime><MsgType>event</MsgType><Event>change_contact</Event>`
+	tests := []struct {
+		name       string
+		data       string
+		want       *ContactChange
+		wantFields []string
+		wantErr    string
+	}{
+		{
+			name: "create user",
+			data: head + `<ChangeType>create_user</ChangeType><UserID>zhangsan</UserID><Name>张三</Name><Department>1,2</Department><MainDepartment>1</MainDepartment><IsLeaderInDept>1,0</IsLeaderInDept><DirectLeader>lisi,wangwu</DirectLeader><Gender>1</Gender><Status>1</Status></xml>`,
+			want: &ContactChange{ChangeType: ContactCreateUser, User: &wxwork.User{
+				Userid: "zhangsan", Name: "张三", Department: []int64{1, 2}, MainDepartment: 1,
+				IsLeaderInDept: []int{1, 0}, DirectLeader: []string{"lisi", "wangwu"}, Gender: "1", Status: 1,
+			}},
+			wantFields: []string{"Department", "DirectLeader", "Gender", "IsLeaderInDept", "MainDepartment", "Name", "Status", "UserID"},
+		},
+		{
+			name:       "update user renames userid",
+			data:       head + `<ChangeType>update_user</ChangeType><UserID>zhangsan</UserID><NewUserID>zhangsan2</NewUserID><Mobile>13800000000</Mobile></xml>`,
+			want:       &ContactChange{ChangeType: ContactUpdateUser, NewUserid: "zhangsan2", User: &wxwork.User{Userid: "zhangsan", Mobile: "13800000000"}},
+			wantFields: []string{"Mobile", "NewUserID", "UserID"},
+		},
+		{
+			name:       "delete party",
+			data:       head + `<ChangeType>delete_party</ChangeType><Id>2</Id></xml>`,
+			want:       &ContactChange{ChangeType: ContactDeleteParty, Party: &wxwork.Department{Id: 2}},
+			wantFields: []string{"Id"},
+		},
+		{
+			name:       "update party",
+			data:       head + `<ChangeType>update_party</ChangeType><Id>2</Id><Name>研发</Name><ParentId>1</ParentId></xml>`,
+			want:       &ContactChange{ChangeType: ContactUpdateParty, Party: &wxwork.Department{Id: 2, Name: "研发", Parentid: 1}},
+			wantFields: []string{"Id", "Name", "ParentId"},
+		},
+		{
+			name: "update tag",
+			data: head + `<ChangeType>update_tag</ChangeType><TagId>3</TagId><AddUserItems>a,b</AddUserItems><DelPartyItems>4</DelPartyItems></xml>`,
+			want: &ContactChange{ChangeType: ContactUpdateTag, Tag: &ContactTagChange{
+				Tagid: 3, AddUserids: []string{"a", "b"}, DelPartyids: []int64{4},
+			}},
+			wantFields: []string{"AddUserItems", "DelPartyItems", "TagId"},
+		},
+		{
+			name:    "unknown change type",
+			data:    head + `<ChangeType>rename_corp</ChangeType></xml>`,
+			wantErr: "unknown contact change type: rename_corp",
+		},
+		{
+			name:    "not a contact event",
+			data:    `<xml><MsgType>event</MsgType><Event>enter_agent</Event></xml>`,
+			wantErr: "not a change_contact event: enter_agent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := parseMessage(t, "corp1", tt.data)
+			change, err := ParseContactChange(msg)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if change.Corpid != msg.ctx.AppidMain() || !change.Time.Equal(time.Unix(1700000000, 0)) {
+				t.Errorf("corpid = %q, time = %s", change.Corpid, change.Time)
+			}
+			sort.Strings(change.Fields)
+			if !reflect.DeepEqual(change.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", change.Fields, tt.wantFields)
+			}
+			tt.want.Corpid, tt.want.Time, tt.want.Fields = change.Corpid, change.Time, change.Fields
+			if !reflect.DeepEqual(change, tt.want) {
+				t.Errorf("change = %+v, want %+v", change, tt.want)
+			}
+		})
+	}
+}
+
+type fakeDirectory struct {
+	applied []*ContactChange
+	err     error
+}
+
+func (d *fakeDirectory) Apply(change *ContactChange) error {
+	d.applied = append(d.applied, change)
+	return d.err
+}
+func (d *fakeDirectory) Replace(corpid string, snapshot *DirectorySnapshot) error {
+	return nil
+}
+
+func TestContactSyncApply(t *testing.T) {
+	setupTest(t)
+	data := `<xml><ToUserName>corp1</ToUserName><MsgType>event</MsgType><Event>change_contact</Event><ChangeType>delete_user</ChangeType><UserID>zhangsan</UserID></xml>`
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{"applied", nil, nil},
+		{"out of sync", ErrNeedResync, ErrNeedResync},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeDirectory{err: tt.err}
+			err := NewContactSync(store).Apply(parseMessage(t, "corp1", data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(store.applied) != 1 || store.applied[0].User.Userid != "zhangsan" {
+				t.Errorf("applied = %+v", store.applied)
+			}
+		})
+	}
+}
+
+func TestResyncReleasesLockOnFailure(t *testing.T) {
+	setupTest(t)
+	s := NewContactSync(&fakeDirectory{})
+	c, err := App("wxmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := zwx.PrefixNotify.Key("wxmp", "contact_resync")
+	// 非企业微信应用，Resync失败，不请求微信接口
+	s.resyncOnce("wxmp")
+	if c.Storage().Get(key) != "" {
+		t.Error("resync lock kept after failure")
+	}
+}
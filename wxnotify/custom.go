@@ -0,0 +1,106 @@
+package wxnotify
+
+import (
+	"errors"
+	"github.com/zohu/zwx"
+)
+
+// SendCustom
+// @Description: 将被动回复改为主动发送，公众号、小程序使用客服消息接口(需在用户互动48小时内)，企业微信使用应用消息接口(需配置agent_id)
+// @receiver c
+// @param reply
+// @return error
+func (c *Context) SendCustom(reply Reply) error {
+	body, err := customBody(reply)
+	if err != nil {
+		return c.ErrorWith("custom_send", err)
+	}
+	api := zwx.ApiCgiBin.WithPath("message/custom/send")
+	if c.IsWork() {
+		api = zwx.ApiWorkCgiBin.WithPath("message/send")
+		if c.AgentId() == "" {
+			return c.Error("custom_send", "agent_id is required")
+		}
+		body["agentid"] = c.AgentId()
+	}
+	var resp zwx.WxResponse
+	if err = zwx.NewHttp(zwx.MethodPost, api).
+		SetAccessTokenFrom(c.Context).
+		SetJson(body).
+		BindJson(&resp).
+		Debug(c.IsDebug(), c.Logger()).
+		Do(); err != nil {
+		return c.ErrorWith("custom_send", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.SendCustom(reply)
+		}
+		return c.ErrorFrom("custom_send", resp)
+	}
+	return nil
+}
+
+// customBody 被动回复转为客服消息结构
+func customBody(reply Reply) (map[string]any, error) {
+	switch r := reply.(type) {
+	case *MessageReplyArticles:
+		articles := make([]map[string]string, 0, len(r.Articles))
+		for _, a := range r.Articles {
+			articles = append(articles, map[string]string{
+				"title":       a.Title,
+				"description": a.Description,
+				"url":         a.URL,
+				"picurl":      a.PicURL,
+			})
+		}
+		return map[string]any{
+			"touser":  r.ToUserName,
+			"msgtype": MessageTypeNews,
+			"news":    map[string]any{"articles": articles},
+		}, nil
+	case *MessageReply:
+		body := map[string]any{"touser": r.ToUserName, "msgtype": r.MsgType}
+		switch r.MsgType {
+		case MessageTypeText:
+			body["text"] = map[string]string{"content": r.Content}
+		case MessageTypeImage:
+			if r.Image == nil {
+				return nil, errors.New("image reply without media")
+			}
+			body["image"] = map[string]string{"media_id": r.Image.MediaID}
+		case MessageTypeVoice:
+			if r.Voice == nil {
+				return nil, errors.New("voice reply without media")
+			}
+			body["voice"] = map[string]string{"media_id": r.Voice.MediaID}
+		case MessageTypeVideo:
+			if r.Video == nil {
+				return nil, errors.New("video reply without media")
+			}
+			body["video"] = map[string]string{
+				"media_id":    r.Video.MediaID,
+				"title":       r.Video.Title,
+				"description": r.Video.Description,
+			}
+		case MessageTypeMusic:
+			if r.Music == nil {
+				return nil, errors.New("music reply without music")
+			}
+			body["music"] = map[string]string{
+				"title":          r.Music.Title,
+				"description":    r.Music.Description,
+				"musicurl":       r.Music.MusicURL,
+				"hqmusicurl":     r.Music.HQMusicURL,
+				"thumb_media_id": r.Music.ThumbMediaID,
+			}
+		default:
+			return nil, errors.New("unsupported reply type " + string(r.MsgType))
+		}
+		return body, nil
+	case nil:
+		return nil, errors.New("empty reply")
+	default:
+		return nil, errors.New("unsupported reply")
+	}
+}
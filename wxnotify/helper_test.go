@@ -0,0 +1,37 @@
+package wxnotify
+
+import (
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/internal/zwxtest"
+	"testing"
+	"time"
+)
+
+const (
+	testToken  = "tok"
+	testAesKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+	testNow    = 1700000000
+)
+
+// setupTest 托管公众号wxmp与企业微信corp1，按需获取token且时钟固定，测试中不会请求微信接口
+func setupTest(t *testing.T) {
+	t.Helper()
+	zwxtest.Setup(t, time.Unix(testNow, 0),
+		zwx.App{AppType: zwx.TypeWxMpServe, Appid: "wxmp", AppSecret: "s", Token: testToken, EncodingAesKey: testAesKey},
+		zwx.App{AppType: zwx.TypeWxWork, Appid: "corp1", AppSecret: "s", AgentId: "1000002", Token: testToken, EncodingAesKey: testAesKey},
+	)
+}
+
+// parseMessage 跳过签名与解密，直接解析明文消息体
+func parseMessage(t *testing.T, appid, data string) *Message {
+	t.Helper()
+	c, err := App(appid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := c.parse(&ReqNotify{Nonce: "nonce"}, []byte(data), isJsonBody([]byte(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
@@ -1,17 +1,27 @@
 package wxnotify
 
 import (
+	"bytes"
+	"encoding/json"
 	"encoding/xml"
+	"github.com/bytedance/sonic"
+	"strconv"
+	"strings"
 )
 
 type ReqNotify struct {
+	Signature    string `json:"signature,omitempty"`
 	MsgSignature string `json:"msg_signature"`
 	Timestamp    string `json:"timestamp"`
 	Nonce        string `json:"nonce"`
 	Echostr      string `json:"echostr,omitempty"`
 	EncryptType  string `json:"encrypt_type,omitempty"`
+	Openid       string `json:"openid,omitempty"`
 }
 
+// EncryptTypeAes 安全模式、兼容模式下推送的encrypt_type
+const EncryptTypeAes = "aes"
+
 // MessageType
 // @Description: 消息类型
 type MessageType string  // 消息类型
@@ -71,6 +81,7 @@ const (
 	MessageWorkTypeChangeContact      MessageEvent = "change_contact"           // 通讯录变更
 	MessageEventBatchJobResult        MessageEvent = "batch_job_result"         // 异步任务完成
 	MessageEventEnterAgent            MessageEvent = "enter_agent"              // 进入应用
+	MessageEventClickWork             MessageEvent = "click"                    // 点击菜单拉取消息时的事件
 	MessageEventViewWork              MessageEvent = "view"                     // 点击菜单跳转链接时的事件推送
 	MessageEventOpenApprovalChange    MessageEvent = "open_approval_change"     // 审批状态通知
 	MessageEventShareAgentChange      MessageEvent = "share_agent_change"       // 企业互联共享应用事件回调
@@ -87,16 +98,34 @@ const (
 	MessageEventNotifyThirdFasterRegister MessageEvent = "notify_third_fasteregister" // 注册审核事件推送
 )
 
+// workEvents 企业微信中与公众号同义的事件
+var workEvents = map[MessageEvent]MessageEvent{
+	MessageEventClickWork: MessageEventClick,
+	MessageEventViewWork:  MessageEventView,
+}
+
+// canonical 企业微信的同义事件统一为公众号事件，用于路由匹配与类型解析
+func (e MessageEvent) canonical() MessageEvent {
+	if c, ok := workEvents[e]; ok {
+		return c
+	}
+	return e
+}
+
 // CommonMessage
 // @Description: 消息中通用的结构
 type CommonMessage struct {
-	XMLName      xml.Name    `xml:"xml"`
+	XMLName      xml.Name    `json:"-" xml:"xml"`
 	ToUserName   string      `json:"ToUserName" xml:"ToUserName"`
 	FromUserName string      `json:"FromUserName" xml:"FromUserName"`
 	CreateTime   int64       `json:"CreateTime" xml:"CreateTime"`
 	MsgType      MessageType `json:"MsgType" xml:"MsgType"`
 	Nonce        string      `json:"-" xml:"-"`
 	ctx          *Context
+	server       *Server // 接收该消息的回调服务，直接解析的消息为nil
+	encrypted    bool
+	json         bool
+	raw          []byte
 }
 
 // Message
@@ -119,6 +148,10 @@ type Message struct {
 	Title         string `json:"Title,omitempty" xml:"Title,omitempty"`               // 链接消息，标题
 	Description   string `json:"Description,omitempty" xml:"Description,omitempty"`   // 链接消息，描述
 	Url           string `json:"Url,omitempty" xml:"Url,omitempty"`                   // 链接消息
+	MiniAppId     string `json:"AppId,omitempty" xml:"AppId,omitempty"`               // 小程序卡片消息，小程序appid
+	PagePath      string `json:"PagePath,omitempty" xml:"PagePath,omitempty"`         // 小程序卡片消息，小程序页面路径
+	ThumbUrl      string `json:"ThumbUrl,omitempty" xml:"ThumbUrl,omitempty"`         // 小程序卡片消息，封面图片的临时cdn链接
+	SessionFrom   string `json:"SessionFrom,omitempty" xml:"SessionFrom,omitempty"`   // 小程序进入客服会话事件，客服按钮的session-from
 
 	// 事件消息
 	Event      MessageEvent `json:"Event,omitempty" xml:"Event,omitempty"`           // 事件消息
@@ -153,10 +186,117 @@ type Message struct {
 	SendPicsInfo     *SendPicsInfo     `json:"SendPicsInfo,omitempty" xml:"SendPicsInfo,omitempty"`         // 发送的图片信息
 	SendLocationInfo *SendLocationInfo `json:"SendLocationInfo,omitempty" xml:"SendLocationInfo,omitempty"` // 发送的位置信息
 	ApprovalInfo     *ApprovalInfo     `json:"ApprovalInfo,omitempty" xml:"ApprovalInfo,omitempty"`         // 审批信息
+
+	// 开放平台，解析时InfoType同步到Event，MsgType视为event
+	InfoType                     MessageEvent `json:"InfoType,omitempty" xml:"InfoType,omitempty"`                                         // 通知类型
+	ComponentVerifyTicket        string       `json:"ComponentVerifyTicket,omitempty" xml:"ComponentVerifyTicket,omitempty"`               // component_verify_ticket
+	AuthorizerAppid              string       `json:"AuthorizerAppid,omitempty" xml:"AuthorizerAppid,omitempty"`                           // 授权方appid
+	AuthorizationCode            string       `json:"AuthorizationCode,omitempty" xml:"AuthorizationCode,omitempty"`                       // 授权码
+	AuthorizationCodeExpiredTime int64        `json:"AuthorizationCodeExpiredTime,omitempty" xml:"AuthorizationCodeExpiredTime,omitempty"` // 授权码过期时间
+	PreAuthCode                  string       `json:"PreAuthCode,omitempty" xml:"PreAuthCode,omitempty"`                                   // 预授权码
 	TemplateCard
 	UserEvent
 	CustomerEvent
 }
+
+// qrscenePrefix 未关注用户扫码关注时EventKey的前缀
+const qrscenePrefix = "qrscene_"
+
+// Context
+// @Description: 消息所属APP
+// @receiver msg
+// @return *Context
+func (msg *Message) Context() *Context {
+	return msg.ctx
+}
+
+// IsEncrypted
+// @Description: 消息是否为加密推送，决定被动回复是否加密
+// @receiver msg
+// @return bool
+func (msg *Message) IsEncrypted() bool {
+	return msg.encrypted
+}
+
+// DedupKey
+// @Description: 消息去重键，普通消息使用MsgId，事件使用FromUserName+CreateTime+Event，开放平台通知使用AuthorizerAppid+CreateTime+InfoType
+// @receiver msg
+// @return string
+func (msg *Message) DedupKey() string {
+	if msg.MsgId != 0 {
+		return strconv.FormatInt(msg.MsgId, 10)
+	}
+	if msg.InfoType != "" {
+		return msg.AuthorizerAppid + ":" + strconv.FormatInt(msg.CreateTime, 10) + ":" + string(msg.InfoType)
+	}
+	return msg.FromUserName + ":" + strconv.FormatInt(msg.CreateTime, 10) + ":" + string(msg.Event)
+}
+
+// Raw
+// @Description: 解密后的原始消息体，XML或JSON
+// @receiver msg
+// @return []byte
+func (msg *Message) Raw() []byte {
+	return msg.raw
+}
+
+// IsJson
+// @Description: 消息是否为JSON格式推送，决定被动回复的格式
+// @receiver msg
+// @return bool
+func (msg *Message) IsJson() bool {
+	return msg.json
+}
+
+// parseJsonList JSON格式的订阅通知事件使用List字段，单条时为对象，数值字段为字符串
+func (msg *Message) parseJsonList(data []byte) error {
+	var raw struct {
+		List json.RawMessage `json:"List"`
+	}
+	if err := sonic.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	list := bytes.TrimSpace(raw.List)
+	if len(list) == 0 || bytes.Equal(list, []byte("null")) {
+		return nil
+	}
+	var items []map[string]any
+	if list[0] == '{' {
+		item := make(map[string]any)
+		if err := sonic.Unmarshal(list, &item); err != nil {
+			return err
+		}
+		items = append(items, item)
+	} else if err := sonic.Unmarshal(list, &items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		for _, k := range []string{"PopupScene", "ErrorCode"} {
+			if v, ok := item[k].(string); ok {
+				item[k], _ = strconv.ParseInt(v, 10, 64)
+			}
+		}
+	}
+	d, _ := sonic.Marshal(items)
+	switch msg.Event {
+	case MessageEventSubscribeMsgPopupEvent:
+		return sonic.Unmarshal(d, &msg.SubscribeMsgPopupEvent.SubscribeMsgPopupEvent)
+	case MessageEventSubscribeMsgChangeEvent:
+		return sonic.Unmarshal(d, &msg.SubscribeMsgChangeEvent.SubscribeMsgChangeEvent)
+	case MessageEventSubscribeMsgSentEvent:
+		return sonic.Unmarshal(d, &msg.SubscribeMsgSentEvent.SubscribeMsgSentEvent)
+	}
+	return nil
+}
+
+// SceneKey
+// @Description: 带参二维码的场景值，去掉了未关注扫码时的qrscene_前缀
+// @receiver msg
+// @return string
+func (msg *Message) SceneKey() string {
+	return strings.TrimPrefix(msg.EventKey, qrscenePrefix)
+}
+
 type BatchJob struct {
 	JobId   string `json:"JobId,omitempty" xml:"JobId,omitempty"`     // 异步任务ID
 	JobType string `json:"JobType,omitempty" xml:"JobType,omitempty"` // 操作类型，字符串，目前分别有：sync_user(增量更新成员)、 replace_user(全量覆盖成员）、invite_user(邀请成员关注）、replace_party(全量覆盖部门)
@@ -292,41 +432,47 @@ type TransInfo struct {
 type MessageReply struct {
 	CommonMessage
 	// 回复文本
-	Content string `xml:"Content,omitempty"`
+	Content string `json:"Content,omitempty" xml:"Content,omitempty"`
 	// 回复图片
-	Image *MediaID `xml:"Image,omitempty"`
+	Image *MediaID `json:"Image,omitempty" xml:"Image,omitempty"`
 	// 回复录音
-	Voice *MediaID `xml:"Voice,omitempty"`
+	Voice *MediaID `json:"Voice,omitempty" xml:"Voice,omitempty"`
 	// 回复视频
-	Video *Video `xml:"Video,omitempty"`
+	Video *Video `json:"Video,omitempty" xml:"Video,omitempty"`
 	// 回复音乐
-	Music *Music `xml:"Music,omitempty"`
+	Music *Music `json:"Music,omitempty" xml:"Music,omitempty"`
 	// 回复图文
-	ArticleCount int `xml:"ArticleCount,omitempty"`
+	ArticleCount int `json:"ArticleCount,omitempty" xml:"ArticleCount,omitempty"`
+	// 转发到指定客服
+	TransInfo *TransInfo `json:"TransInfo,omitempty" xml:"TransInfo,omitempty"`
+	// 企业微信更新按钮文案
+	Button *ReplyButton `json:"Button,omitempty" xml:"Button,omitempty"`
+	// 企业微信更新模板卡片
+	TemplateCard *ReplyTemplateCard `json:"TemplateCard,omitempty" xml:"TemplateCard,omitempty"`
 }
 type MessageReplyArticles struct {
 	MessageReply
-	Articles []*Article `xml:"Articles>item,omitempty"`
+	Articles []*Article `json:"Articles,omitempty" xml:"Articles>item,omitempty"`
 }
 
 type MediaID struct {
-	MediaID string `xml:"MediaId,omitempty"`
+	MediaID string `json:"MediaId,omitempty" xml:"MediaId,omitempty"`
 }
 type Video struct {
-	MediaID     string `xml:"MediaId,omitempty"`
-	Title       string `xml:"Title,omitempty"`
-	Description string `xml:"Description,omitempty"`
+	MediaID     string `json:"MediaId,omitempty" xml:"MediaId,omitempty"`
+	Title       string `json:"Title,omitempty" xml:"Title,omitempty"`
+	Description string `json:"Description,omitempty" xml:"Description,omitempty"`
 }
 type Music struct {
-	Title        string `xml:"Title,omitempty"`
-	Description  string `xml:"Description,omitempty"`
-	MusicURL     string `xml:"MusicUrl,omitempty"`
-	HQMusicURL   string `xml:"HQMusicUrl,omitempty"`
-	ThumbMediaID string `xml:"ThumbMediaId,omitempty"`
+	Title        string `json:"Title,omitempty" xml:"Title,omitempty"`
+	Description  string `json:"Description,omitempty" xml:"Description,omitempty"`
+	MusicURL     string `json:"MusicUrl,omitempty" xml:"MusicUrl,omitempty"`
+	HQMusicURL   string `json:"HQMusicUrl,omitempty" xml:"HQMusicUrl,omitempty"`
+	ThumbMediaID string `json:"ThumbMediaId,omitempty" xml:"ThumbMediaId,omitempty"`
 }
 type Article struct {
-	Title       string `xml:"Title,omitempty"`
-	Description string `xml:"Description,omitempty"`
-	PicURL      string `xml:"PicUrl,omitempty"`
-	URL         string `xml:"Url,omitempty"`
+	Title       string `json:"Title,omitempty" xml:"Title,omitempty"`
+	Description string `json:"Description,omitempty" xml:"Description,omitempty"`
+	PicURL      string `json:"PicUrl,omitempty" xml:"PicUrl,omitempty"`
+	URL         string `json:"Url,omitempty" xml:"Url,omitempty"`
 }
@@ -0,0 +1,135 @@
+package wxnotify
+
+import (
+	"github.com/zohu/zwx/wxcpt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type listItem struct {
+	TemplateId string
+	Status     string
+	Code       int64
+}
+
+// listItems 统一三种订阅通知列表，便于表格比较
+func listItems(msg *Message) []listItem {
+	var items []listItem
+	for _, v := range msg.SubscribeMsgPopupEvent.SubscribeMsgPopupEvent {
+		items = append(items, listItem{v.TemplateId, v.SubscribeStatusString, v.PopupScene})
+	}
+	for _, v := range msg.SubscribeMsgChangeEvent.SubscribeMsgChangeEvent {
+		items = append(items, listItem{v.TemplateId, v.SubscribeStatusString, 0})
+	}
+	for _, v := range msg.SubscribeMsgSentEvent.SubscribeMsgSentEvent {
+		items = append(items, listItem{v.TemplateId, v.ErrorStatus, v.ErrorCode})
+	}
+	return items
+}
+
+func TestDecodeJson(t *testing.T) {
+	setupTest(t)
+	tests := []struct {
+		name      string
+		data      string
+		wantType  MessageType
+		wantEvent MessageEvent
+		wantItems []listItem
+	}{
+		{
+			name:     "text",
+			data:     `{"ToUserName":"gh_1","FromUserName":"u1","CreateTime":100,"MsgType":"text","Content":"hi","MsgId":42}`,
+			wantType: MessageTypeText,
+		},
+		{
+			name:      "popup list as object with string scene",
+			data:      `{"ToUserName":"gh_1","FromUserName":"u1","CreateTime":100,"MsgType":"event","Event":"subscribe_msg_popup_event","List":{"TemplateId":"t1","SubscribeStatusString":"accept","PopupScene":"2"}}`,
+			wantType:  MessageTypeEvent,
+			wantEvent: MessageEventSubscribeMsgPopupEvent,
+			wantItems: []listItem{{"t1", "accept", 2}},
+		},
+		{
+			name:      "popup list as array",
+			data:      `{"ToUserName":"gh_1","FromUserName":"u1","CreateTime":100,"MsgType":"event","Event":"subscribe_msg_popup_event","List":[{"TemplateId":"t1","SubscribeStatusString":"accept","PopupScene":"1"},{"TemplateId":"t2","SubscribeStatusString":"reject","PopupScene":2}]}`,
+			wantType:  MessageTypeEvent,
+			wantEvent: MessageEventSubscribeMsgPopupEvent,
+			wantItems: []listItem{{"t1", "accept", 1}, {"t2", "reject", 2}},
+		},
+		{
+			name:      "change list",
+			data:      `{"ToUserName":"gh_1","FromUserName":"u1","CreateTime":100,"MsgType":"event","Event":"subscribe_msg_change_event","List":[{"TemplateId":"t1","SubscribeStatusString":"reject"}]}`,
+			wantType:  MessageTypeEvent,
+			wantEvent: MessageEventSubscribeMsgChangeEvent,
+			wantItems: []listItem{{"t1", "reject", 0}},
+		},
+		{
+			name:      "sent list with string error code",
+			data:      `{"ToUserName":"gh_1","FromUserName":"u1","CreateTime":100,"MsgType":"event","Event":"subscribe_msg_sent_event","List":{"TemplateId":"t1","MsgID":"9","ErrorCode":"43101","ErrorStatus":"user refuse to accept the msg"}}`,
+			wantType:  MessageTypeEvent,
+			wantEvent: MessageEventSubscribeMsgSentEvent,
+			wantItems: []listItem{{"t1", "user refuse to accept the msg", 43101}},
+		},
+		{
+			name:      "xml popup list maps to the same model",
+			data:      `<xml><ToUserName>gh_1</ToUserName><FromUserName>u1</FromUserName><CreateTime>100</CreateTime><MsgType>event</MsgType><Event>subscribe_msg_popup_event</Event><SubscribeMsgPopupEvent><List><TemplateId>t1</TemplateId><SubscribeStatusString>accept</SubscribeStatusString><PopupScene>2</PopupScene></List></SubscribeMsgPopupEvent></xml>`,
+			wantType:  MessageTypeEvent,
+			wantEvent: MessageEventSubscribeMsgPopupEvent,
+			wantItems: []listItem{{"t1", "accept", 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := parseMessage(t, "wxmp", tt.data)
+			if msg.IsJson() != strings.HasPrefix(tt.data, "{") {
+				t.Errorf("IsJson() = %t", msg.IsJson())
+			}
+			if msg.MsgType != tt.wantType || msg.Event != tt.wantEvent {
+				t.Errorf("type = %s/%s, want %s/%s", msg.MsgType, msg.Event, tt.wantType, tt.wantEvent)
+			}
+			if msg.FromUserName != "u1" || msg.CreateTime != 100 {
+				t.Errorf("common fields = %+v", msg.CommonMessage)
+			}
+			if got := listItems(msg); !reflect.DeepEqual(got, tt.wantItems) {
+				t.Errorf("items = %+v, want %+v", got, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestServerJsonReply(t *testing.T) {
+	setupTest(t)
+	s := NewServer("wxmp", func(msg *Message) Reply { return msg.ReplyText("pong") })
+	w := postPlain(s, `{"ToUserName":"gh_1","FromUserName":"u1","CreateTime":100,"MsgType":"text","Content":"ping","MsgId":42}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("content type = %q, want json", ct)
+	}
+	for _, want := range []string{`"ToUserName":"u1"`, `"FromUserName":"gh_1"`, `"MsgType":"text"`, `"Content":"pong"`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("reply %s missing %s", w.Body.String(), want)
+		}
+	}
+}
+
+// xmlOnlyReply 只实现Reply，不支持JSON
+type xmlOnlyReply struct{}
+
+func (xmlOnlyReply) Encrypted() *wxcpt.BizMsgSendXml { return nil }
+func (xmlOnlyReply) Plain() []byte                   { return []byte("<xml></xml>") }
+
+func TestServerJsonReplyRequiresJsonReply(t *testing.T) {
+	setupTest(t)
+	s := NewServer("wxmp", func(msg *Message) Reply { return xmlOnlyReply{} })
+	w := postPlain(s, `{"ToUserName":"gh_1","FromUserName":"u1","CreateTime":100,"MsgType":"text","Content":"ping","MsgId":43}`)
+	if w.Code != http.StatusOK || w.Body.String() != "success" {
+		t.Errorf("response = %d %q, want success", w.Code, w.Body.String())
+	}
+	w = postPlain(s, `<xml><ToUserName>gh_1</ToUserName><FromUserName>u1</FromUserName><CreateTime>100</CreateTime><MsgType>text</MsgType><Content>ping</Content><MsgId>44</MsgId></xml>`)
+	if w.Body.String() != "<xml></xml>" {
+		t.Errorf("xml response = %q", w.Body.String())
+	}
+}
@@ -2,13 +2,24 @@ package wxnotify
 
 import (
 	"encoding/xml"
+	"github.com/bytedance/sonic"
+	"github.com/zohu/zwx/utils"
 	"github.com/zohu/zwx/wxcpt"
 	"strconv"
-	"time"
 )
 
+func encryptJsonMsg(ctx *Context, data []byte, timestamp int64, nonce string) *wxcpt.BizMsgSendJson {
+	cpt := ctx.crypt()
+	send, err := cpt.EncryptJsonMsg(string(data), strconv.FormatInt(timestamp, 10), nonce)
+	if err != nil {
+		ctx.Logger().Errorf("加密失败 %s", err.Error())
+		return nil
+	}
+	return send
+}
+
 func encryptMsg(ctx *Context, data []byte, timestamp int64, nonce string) *wxcpt.BizMsgSendXml {
-	cpt := wxcpt.NewBizMsgCrypt(ctx.NotifyToken(), ctx.NotifyEncodingAesKey(), ctx.AppidMain())
+	cpt := ctx.crypt()
 	send, err := cpt.EncryptXmlMsg(string(data), strconv.FormatInt(timestamp, 10), nonce)
 	if err != nil {
 		ctx.Logger().Errorf("加密失败 %s", err.Error())
@@ -25,6 +36,30 @@ func (msg *MessageReplyArticles) Encrypted() *wxcpt.BizMsgSendXml {
 	str, _ := xml.Marshal(msg)
 	return encryptMsg(msg.ctx, str, msg.CreateTime, msg.Nonce)
 }
+func (msg *MessageReply) Plain() []byte {
+	str, _ := xml.Marshal(msg)
+	return str
+}
+func (msg *MessageReplyArticles) Plain() []byte {
+	str, _ := xml.Marshal(msg)
+	return str
+}
+func (msg *MessageReply) EncryptedJson() *wxcpt.BizMsgSendJson {
+	str, _ := sonic.Marshal(msg)
+	return encryptJsonMsg(msg.ctx, str, msg.CreateTime, msg.Nonce)
+}
+func (msg *MessageReplyArticles) EncryptedJson() *wxcpt.BizMsgSendJson {
+	str, _ := sonic.Marshal(msg)
+	return encryptJsonMsg(msg.ctx, str, msg.CreateTime, msg.Nonce)
+}
+func (msg *MessageReply) PlainJson() []byte {
+	str, _ := sonic.Marshal(msg)
+	return str
+}
+func (msg *MessageReplyArticles) PlainJson() []byte {
+	str, _ := sonic.Marshal(msg)
+	return str
+}
 
 // ReplyText
 // @Description: 回复文本消息
@@ -38,7 +73,7 @@ func (msg *Message) ReplyText(content string) *MessageReply {
 	text.MsgType = MessageTypeText
 	text.FromUserName = msg.ToUserName
 	text.ToUserName = msg.FromUserName
-	text.CreateTime = time.Now().Unix()
+	text.CreateTime = utils.Now().Unix()
 	text.Content = content
 	return text
 }
@@ -55,8 +90,8 @@ func (msg *Message) ReplyImage(mediaID string) *MessageReply {
 	image.MsgType = MessageTypeImage
 	image.FromUserName = msg.ToUserName
 	image.ToUserName = msg.FromUserName
-	image.CreateTime = time.Now().Unix()
-	image.Image.MediaID = mediaID
+	image.CreateTime = utils.Now().Unix()
+	image.Image = &MediaID{MediaID: mediaID}
 	return image
 }
 
@@ -72,8 +107,8 @@ func (msg *Message) ReplyVoice(mediaID string) *MessageReply {
 	voice.MsgType = MessageTypeVoice
 	voice.FromUserName = msg.ToUserName
 	voice.ToUserName = msg.FromUserName
-	voice.CreateTime = time.Now().Unix()
-	voice.Voice.MediaID = mediaID
+	voice.CreateTime = utils.Now().Unix()
+	voice.Voice = &MediaID{MediaID: mediaID}
 	return voice
 }
 
@@ -91,10 +126,8 @@ func (msg *Message) ReplyVideo(mediaID, title, description string) *MessageReply
 	video.MsgType = MessageTypeVideo
 	video.FromUserName = msg.ToUserName
 	video.ToUserName = msg.FromUserName
-	video.CreateTime = time.Now().Unix()
-	video.Video.MediaID = mediaID
-	video.Video.Title = title
-	video.Video.Description = description
+	video.CreateTime = utils.Now().Unix()
+	video.Video = &Video{MediaID: mediaID, Title: title, Description: description}
 	return video
 }
 
@@ -114,12 +147,14 @@ func (msg *Message) ReplyMusic(title, description, musicURL, hQMusicURL, thumbMe
 	music.MsgType = MessageTypeMusic
 	music.FromUserName = msg.ToUserName
 	music.ToUserName = msg.FromUserName
-	music.CreateTime = time.Now().Unix()
-	music.Music.Title = title
-	music.Music.Description = description
-	music.Music.MusicURL = musicURL
-	music.Music.HQMusicURL = hQMusicURL
-	music.Music.ThumbMediaID = thumbMediaID
+	music.CreateTime = utils.Now().Unix()
+	music.Music = &Music{
+		Title:        title,
+		Description:  description,
+		MusicURL:     musicURL,
+		HQMusicURL:   hQMusicURL,
+		ThumbMediaID: thumbMediaID,
+	}
 	return music
 }
 
@@ -135,8 +170,61 @@ func (msg *Message) ReplyNews(articles []*Article) *MessageReplyArticles {
 	news.MsgType = MessageTypeNews
 	news.FromUserName = msg.ToUserName
 	news.ToUserName = msg.FromUserName
-	news.CreateTime = time.Now().Unix()
+	news.CreateTime = utils.Now().Unix()
 	news.ArticleCount = len(articles)
 	news.Articles = articles
 	return news
 }
+
+// ReplyTransfer
+// @Description: 将消息转发到客服，小程序JSON格式推送仅支持该回复
+// @receiver msg
+// @param kfAccount 指定客服账号，为空时由系统分配
+// @return *MessageReply
+func (msg *Message) ReplyTransfer(kfAccount string) *MessageReply {
+	transfer := new(MessageReply)
+	transfer.Nonce = msg.Nonce
+	transfer.ctx = msg.ctx
+	transfer.MsgType = MessageTypeTransfer
+	transfer.FromUserName = msg.ToUserName
+	transfer.ToUserName = msg.FromUserName
+	transfer.CreateTime = utils.Now().Unix()
+	if kfAccount != "" {
+		transfer.TransInfo = &TransInfo{KfAccount: kfAccount}
+	}
+	return transfer
+}
+
+// ReplyUpdateButton
+// @Description: 企业微信模板卡片事件，更新点击用户的按钮文案
+// @receiver msg
+// @param replaceName 替换后的按钮文案
+// @return *MessageReply
+func (msg *Message) ReplyUpdateButton(replaceName string) *MessageReply {
+	button := new(MessageReply)
+	button.Nonce = msg.Nonce
+	button.ctx = msg.ctx
+	button.MsgType = MessageTypeUpdateButton
+	button.FromUserName = msg.ToUserName
+	button.ToUserName = msg.FromUserName
+	button.CreateTime = utils.Now().Unix()
+	button.Button = &ReplyButton{ReplaceName: replaceName}
+	return button
+}
+
+// ReplyUpdateTemplateCard
+// @Description: 企业微信模板卡片事件，替换点击用户的整张卡片
+// @receiver msg
+// @param card
+// @return *MessageReply
+func (msg *Message) ReplyUpdateTemplateCard(card *ReplyTemplateCard) *MessageReply {
+	update := new(MessageReply)
+	update.Nonce = msg.Nonce
+	update.ctx = msg.ctx
+	update.MsgType = MessageTypeUpdateTemplateCard
+	update.FromUserName = msg.ToUserName
+	update.ToUserName = msg.FromUserName
+	update.CreateTime = utils.Now().Unix()
+	update.TemplateCard = card
+	return update
+}
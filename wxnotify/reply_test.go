@@ -0,0 +1,94 @@
+package wxnotify
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestReplyUpdateXml(t *testing.T) {
+	setupTest(t)
+	msg := parseMessage(t, "corp1", `<xml><ToUserName>corp1</ToUserName><FromUserName>zhangsan</FromUserName><CreateTime>100</CreateTime><MsgType>event</MsgType><Event>template_card_event</Event><EventKey>btn_ok</EventKey><TaskId>task1</TaskId><CardType>button_interaction</CardType><AgentID>1000002</AgentID></xml>`)
+	source := &CardSource{IconUrl: "https://example.com/icon.png", Desc: "企业微信", DescColor: 1}
+	title := &CardTitle{Title: "审批结果", Desc: "已处理"}
+	tests := []struct {
+		name  string
+		reply *MessageReply
+	}{
+		{"update_button", msg.ReplyUpdateButton("已同意")},
+		{"text_notice", msg.ReplyUpdateTemplateCard(&ReplyTemplateCard{
+			CardType:              TemplateCardTextNotice,
+			Source:                source,
+			ActionMenu:            &CardActionMenu{Desc: "更多", ActionList: []*CardActionItem{{Text: "不再提醒", Key: "mute"}, {Text: "查看", Key: "view"}}},
+			MainTitle:             title,
+			EmphasisContent:       &CardTitle{Title: "100", Desc: "积分"},
+			SubTitleText:          "点击查看详情",
+			HorizontalContentList: []*CardHorizontalContent{{KeyName: "申请人", Value: "张三"}, {KeyName: "详情", Value: "链接", Type: 1, Url: "https://example.com"}},
+			JumpList:              []*CardJump{{Type: 1, Title: "官网", Url: "https://example.com"}},
+			CardAction:            &CardJump{Type: 1, Url: "https://example.com"},
+		})},
+		{"news_notice", msg.ReplyUpdateTemplateCard(&ReplyTemplateCard{
+			CardType:            TemplateCardNewsNotice,
+			Source:              source,
+			MainTitle:           title,
+			ImageTextArea:       &CardImageTextArea{Type: 1, Url: "https://example.com", Title: "图文", ImageUrl: "https://example.com/a.png"},
+			CardImage:           &CardImage{Url: "https://example.com/b.png", AspectRatio: 1.3},
+			VerticalContentList: []*CardTitle{{Title: "一", Desc: "1"}, {Title: "二", Desc: "2"}},
+			CardAction:          &CardJump{Type: 2, AppId: "wxapp", PagePath: "pages/index"},
+		})},
+		{"button_interaction", msg.ReplyUpdateTemplateCard(&ReplyTemplateCard{
+			CardType:        TemplateCardButtonInteraction,
+			TaskId:          "task1",
+			MainTitle:       title,
+			ButtonSelection: &CardSelect{QuestionKey: "q1", Title: "原因", SelectedId: "o1", OptionList: []*CardOption{{Id: "o1", Text: "同意"}, {Id: "o2", Text: "驳回"}}},
+			ButtonList:      []*CardButton{{Text: "同意", Style: 1, Key: "btn_ok"}, {Text: "驳回", Style: 2, Key: "btn_no"}},
+			ReplaceText:     "已同意",
+		})},
+		{"vote_interaction", msg.ReplyUpdateTemplateCard(&ReplyTemplateCard{
+			CardType:     TemplateCardVoteInteraction,
+			TaskId:       "task2",
+			MainTitle:    title,
+			CheckBox:     &CardCheckBox{QuestionKey: "q1", Mode: 1, Disable: true, OptionList: []*CardOption{{Id: "o1", Text: "A", IsChecked: true}, {Id: "o2", Text: "B"}}},
+			SubmitButton: &CardButton{Text: "提交", Key: "submit"},
+			ReplaceText:  "已投票",
+		})},
+		{"multiple_interaction", msg.ReplyUpdateTemplateCard(&ReplyTemplateCard{
+			CardType:  TemplateCardMultipleInteraction,
+			TaskId:    "task3",
+			MainTitle: title,
+			SelectList: []*CardSelect{
+				{QuestionKey: "q1", Title: "部门", Disable: true, SelectedId: "d1", OptionList: []*CardOption{{Id: "d1", Text: "研发"}}},
+				{QuestionKey: "q2", Title: "城市", OptionList: []*CardOption{{Id: "c1", Text: "深圳"}, {Id: "c2", Text: "上海"}}},
+			},
+			SubmitButton: &CardButton{Text: "提交", Key: "submit"},
+			ReplaceText:  "已提交",
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			golden(t, tt.name, append(tt.reply.Plain(), '\n'))
+		})
+	}
+}
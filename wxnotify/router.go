@@ -0,0 +1,266 @@
+package wxnotify
+
+import (
+	"github.com/zohu/zwx"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// HandlerFunc
+// @Description: 路由处理函数，返回nil表示不被动回复
+type HandlerFunc func(msg *Message) Reply
+
+// Middleware
+// @Description: 中间件，如日志、恢复、鉴权
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// 匹配优先级，数值越小越优先，同优先级按注册顺序
+const (
+	priorityExact   = iota // 关键词、菜单KEY
+	priorityPattern        // 正则、扫码场景前缀
+	priorityEvent          // 事件类型
+	priorityType           // 消息类型
+)
+
+type route struct {
+	priority int
+	match    func(msg *Message) bool
+	handler  HandlerFunc
+}
+
+// Router
+// @Description: 按MsgType、Event、EventKey分发消息，可直接作为Dispatcher使用，如 wxnotify.Handler(appid, router.Dispatch)
+type Router struct {
+	middlewares []Middleware
+	routes      []*route
+	fallback    HandlerFunc
+}
+
+// NewRouter
+// @Description: 创建路由
+// @return *Router
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Use
+// @Description: 注册中间件，先注册的在外层
+// @receiver r
+// @param middlewares
+// @return *Router
+func (r *Router) Use(middlewares ...Middleware) *Router {
+	r.middlewares = append(r.middlewares, middlewares...)
+	return r
+}
+
+// Handle
+// @Description: 自定义匹配规则
+// @receiver r
+// @param match
+// @param h
+// @return *Router
+func (r *Router) Handle(match func(msg *Message) bool, h HandlerFunc) *Router {
+	return r.add(priorityPattern, match, h)
+}
+
+// OnMessage
+// @Description: 按消息类型匹配
+// @receiver r
+// @param t
+// @param h
+// @return *Router
+func (r *Router) OnMessage(t MessageType, h HandlerFunc) *Router {
+	return r.add(priorityType, func(msg *Message) bool {
+		return msg.MsgType == t
+	}, h)
+}
+
+// OnText
+// @Description: 匹配全部文本消息
+// @receiver r
+// @param h
+// @return *Router
+func (r *Router) OnText(h HandlerFunc) *Router {
+	return r.OnMessage(MessageTypeText, h)
+}
+
+// OnKeyword
+// @Description: 文本消息去掉首尾空白后与任一关键词完全相同
+// @receiver r
+// @param h
+// @param keywords
+// @return *Router
+func (r *Router) OnKeyword(h HandlerFunc, keywords ...string) *Router {
+	return r.add(priorityExact, func(msg *Message) bool {
+		if msg.MsgType != MessageTypeText {
+			return false
+		}
+		content := strings.TrimSpace(msg.Content)
+		for _, k := range keywords {
+			if content == k {
+				return true
+			}
+		}
+		return false
+	}, h)
+}
+
+// OnRegexp
+// @Description: 文本消息匹配正则，表达式非法时panic
+// @receiver r
+// @param expr
+// @param h
+// @return *Router
+func (r *Router) OnRegexp(expr string, h HandlerFunc) *Router {
+	re := regexp.MustCompile(expr)
+	return r.add(priorityPattern, func(msg *Message) bool {
+		return msg.MsgType == MessageTypeText && re.MatchString(msg.Content)
+	}, h)
+}
+
+// OnEvent
+// @Description: 按事件类型匹配，公众号与企业微信的同类事件互相匹配，如MessageEventClick也匹配企业微信的click
+// @receiver r
+// @param e
+// @param h
+// @return *Router
+func (r *Router) OnEvent(e MessageEvent, h HandlerFunc) *Router {
+	return r.add(priorityEvent, func(msg *Message) bool {
+		return msg.MsgType == MessageTypeEvent && msg.Event.canonical() == e.canonical()
+	}, h)
+}
+
+// OnClick
+// @Description: 点击菜单拉取消息事件，按菜单KEY匹配，兼容公众号CLICK与企业微信click
+// @receiver r
+// @param key
+// @param h
+// @return *Router
+func (r *Router) OnClick(key string, h HandlerFunc) *Router {
+	return r.add(priorityExact, func(msg *Message) bool {
+		return msg.MsgType == MessageTypeEvent && msg.Event.canonical() == MessageEventClick && msg.EventKey == key
+	}, h)
+}
+
+// OnScan
+// @Description: 扫描带参二维码，包括已关注的SCAN事件和未关注扫码后的subscribe事件，按场景值前缀匹配，场景值可通过msg.SceneKey()获取
+// @receiver r
+// @param prefix
+// @param h
+// @return *Router
+func (r *Router) OnScan(prefix string, h HandlerFunc) *Router {
+	return r.add(priorityPattern, func(msg *Message) bool {
+		if msg.MsgType != MessageTypeEvent {
+			return false
+		}
+		if msg.Event != MessageEventScan && (msg.Event != MessageEventSubscribe || !strings.HasPrefix(msg.EventKey, qrscenePrefix)) {
+			return false
+		}
+		return strings.HasPrefix(msg.SceneKey(), prefix)
+	}, h)
+}
+
+// Default
+// @Description: 未匹配任何路由时的处理函数
+// @receiver r
+// @param h
+// @return *Router
+func (r *Router) Default(h HandlerFunc) *Router {
+	r.fallback = h
+	return r
+}
+
+// Dispatch
+// @Description: 分发消息，签名与Dispatcher一致
+// @receiver r
+// @param msg
+// @return Reply
+func (r *Router) Dispatch(msg *Message) Reply {
+	h := r.fallback
+	best := -1
+	for i, rt := range r.routes {
+		if (best < 0 || rt.priority < r.routes[best].priority) && rt.match(msg) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		h = r.routes[best].handler
+	}
+	if h == nil {
+		h = func(*Message) Reply { return nil }
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+	return h(msg)
+}
+
+func (r *Router) add(priority int, match func(msg *Message) bool, h HandlerFunc) *Router {
+	r.routes = append(r.routes, &route{priority: priority, match: match, handler: h})
+	return r
+}
+
+// Recovery
+// @Description: 处理函数panic时记录日志并不回复，避免回调返回5xx
+// @return Middleware
+func Recovery() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(msg *Message) (reply Reply) {
+			defer func() {
+				if err := recover(); err != nil {
+					msg.logger().Errorf("[%s] notify handler panic: %v\n%s", msg.appid(), err, debug.Stack())
+					reply = nil
+				}
+			}()
+			return next(msg)
+		}
+	}
+}
+
+// Logging
+// @Description: 记录消息类型、事件与处理耗时
+// @return Middleware
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(msg *Message) Reply {
+			start := time.Now()
+			reply := next(msg)
+			msg.logger().Infof("[%s] notify from=%s type=%s event=%s key=%s replied=%t cost=%s",
+				msg.appid(), msg.FromUserName, msg.MsgType, msg.Event, msg.EventKey, !isNilReply(reply), time.Since(start))
+			return reply
+		}
+	}
+}
+
+// Filter
+// @Description: 鉴权等前置过滤，allow返回false时不再执行后续处理且不回复
+// @param allow
+// @return Middleware
+func Filter(allow func(msg *Message) bool) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(msg *Message) Reply {
+			if !allow(msg) {
+				return nil
+			}
+			return next(msg)
+		}
+	}
+}
+
+// logger 消息未绑定APP时(如直接构造Message调用处理函数)回退到zwx默认日志
+func (msg *Message) logger() zwx.Logger {
+	if msg.ctx != nil {
+		return msg.ctx.Logger()
+	}
+	return zwx.DefaultLogger()
+}
+
+// appid 消息未绑定APP时使用ToUserName
+func (msg *Message) appid() string {
+	if msg.ctx != nil {
+		return msg.ctx.Appid()
+	}
+	return msg.ToUserName
+}
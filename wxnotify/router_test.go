@@ -0,0 +1,87 @@
+package wxnotify
+
+import (
+	"testing"
+)
+
+func replyWith(content string) HandlerFunc {
+	return func(msg *Message) Reply { return msg.ReplyText(content) }
+}
+
+func TestRouterDispatch(t *testing.T) {
+	setupTest(t)
+	r := NewRouter().
+		OnText(replyWith("text")).
+		OnKeyword(replyWith("keyword"), "help", "帮助").
+		OnRegexp(`^order-\d+$`, replyWith("regexp")).
+		OnEvent(MessageEventSubscribe, replyWith("subscribe")).
+		OnScan("invite-", replyWith("scan")).
+		OnClick("MENU_1", replyWith("click")).
+		OnEvent(MessageEventView, replyWith("view")).
+		Default(replyWith("default"))
+
+	tests := []struct {
+		name  string
+		appid string
+		data  string
+		want  string
+	}{
+		{"text", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>text</MsgType><Content>hi</Content><MsgId>1</MsgId></xml>`, "text"},
+		{"keyword beats text", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>text</MsgType><Content> help </Content><MsgId>2</MsgId></xml>`, "keyword"},
+		{"regexp beats text", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>text</MsgType><Content>order-42</Content><MsgId>3</MsgId></xml>`, "regexp"},
+		{"plain subscribe", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>subscribe</Event></xml>`, "subscribe"},
+		{"subscribe with scene beats event", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>subscribe</Event><EventKey>qrscene_invite-7</EventKey></xml>`, "scan"},
+		{"scan", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>SCAN</Event><EventKey>invite-7</EventKey></xml>`, "scan"},
+		{"scan other prefix", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>SCAN</Event><EventKey>other</EventKey></xml>`, "default"},
+		{"mp click", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>CLICK</Event><EventKey>MENU_1</EventKey></xml>`, "click"},
+		{"work click", "corp1", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>click</Event><EventKey>MENU_1</EventKey><AgentID>1000002</AgentID></xml>`, "click"},
+		{"mp view", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>VIEW</Event><EventKey>https://a.com</EventKey></xml>`, "view"},
+		{"work view matches mp event", "corp1", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>view</Event><EventKey>https://a.com</EventKey><AgentID>1000002</AgentID></xml>`, "view"},
+		{"click other key", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>event</MsgType><Event>CLICK</Event><EventKey>MENU_2</EventKey></xml>`, "default"},
+		{"fallback", "wxmp", `<xml><FromUserName>u1</FromUserName><MsgType>image</MsgType><MsgId>4</MsgId></xml>`, "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := r.Dispatch(parseMessage(t, tt.appid, tt.data))
+			got, ok := reply.(*MessageReply)
+			if !ok {
+				t.Fatalf("reply = %T, want *MessageReply", reply)
+			}
+			if got.Content != tt.want {
+				t.Errorf("reply = %q, want %q", got.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	panics := func(*Message) Reply { panic("boom") }
+	tests := []struct {
+		name     string
+		router   *Router
+		wantNil  bool
+		wantText string
+	}{
+		{"recovery on unbound message", NewRouter().Use(Recovery(), Logging()).Default(panics), true, ""},
+		{"logging on unbound message", NewRouter().Use(Logging()).Default(replyWith("ok")), false, "ok"},
+		{"filter rejects", NewRouter().Use(Filter(func(*Message) bool { return false })).Default(replyWith("ok")), true, ""},
+		{"no route", NewRouter().Use(Recovery()), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := new(Message)
+			msg.ToUserName = "gh_test"
+			msg.MsgType = MessageTypeText
+			reply := tt.router.Dispatch(msg)
+			if tt.wantNil {
+				if !isNilReply(reply) {
+					t.Errorf("reply = %v, want nil", reply)
+				}
+				return
+			}
+			if got := reply.(*MessageReply).Content; got != tt.wantText {
+				t.Errorf("reply = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
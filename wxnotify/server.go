@@ -0,0 +1,284 @@
+package wxnotify
+
+import (
+	"encoding/xml"
+	"errors"
+	"github.com/bytedance/sonic"
+	"github.com/valyala/fasthttp"
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/utils"
+	"github.com/zohu/zwx/wxcpt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// maxBodySize 回调消息体上限
+const maxBodySize = 1 << 20
+
+// Reply
+// @Description: 被动回复，MessageReply、MessageReplyArticles均已实现
+type Reply interface {
+	Encrypted() *wxcpt.BizMsgSendXml
+	Plain() []byte
+}
+
+// JsonReply
+// @Description: 可选实现，支持JSON格式推送的被动回复，MessageReply、MessageReplyArticles均已实现；未实现时JSON推送的消息不被动回复
+type JsonReply interface {
+	EncryptedJson() *wxcpt.BizMsgSendJson
+	PlainJson() []byte
+}
+
+// Dispatcher
+// @Description: 消息处理函数，返回nil表示不被动回复
+type Dispatcher func(msg *Message) Reply
+
+// Server
+// @Description: 回调服务，完成URL验证、解密、分发、回复，支持明文、兼容、安全模式，回复与推送模式一致
+type Server struct {
+	appid       string
+	dispatcher  Dispatcher
+	dedupWindow time.Duration
+	async       *asyncPool
+}
+
+// DefaultDedupWindow 微信在5秒内未收到响应会重试3次，默认在1分钟内去重
+const DefaultDedupWindow = time.Minute
+
+// NewServer
+// @Description: 创建回调服务
+// @param appid
+// @param dispatcher
+// @return *Server
+func NewServer(appid string, dispatcher Dispatcher) *Server {
+	return &Server{appid: appid, dispatcher: dispatcher, dedupWindow: DefaultDedupWindow}
+}
+
+// Dedup
+// @Description: 设置去重窗口，窗口内重复推送的消息不再分发，直接返回首次的回复，0表示关闭去重
+// @receiver s
+// @param window
+// @return *Server
+func (s *Server) Dedup(window time.Duration) *Server {
+	s.dedupWindow = window
+	return s
+}
+
+// Handler
+// @Description: net/http回调处理器，如 mux.Handle("/wx/notify", wxnotify.Handler(appid, dispatcher))
+// @param appid
+// @param dispatcher
+// @return http.Handler
+func Handler(appid string, dispatcher Dispatcher) http.Handler {
+	return NewServer(appid, dispatcher)
+}
+
+// FastHandler
+// @Description: fasthttp回调处理器
+// @param appid
+// @param dispatcher
+// @return fasthttp.RequestHandler
+func FastHandler(appid string, dispatcher Dispatcher) fasthttp.RequestHandler {
+	return NewServer(appid, dispatcher).ServeFastHTTP
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Method == http.MethodPost {
+		var err error
+		if body, err = io.ReadAll(io.LimitReader(r.Body, maxBodySize)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	res := s.serve(r.Method, parseQuery(r.URL.Query()), body)
+	if res.contentType != "" {
+		w.Header().Set("Content-Type", res.contentType)
+	}
+	w.WriteHeader(res.status)
+	_, _ = w.Write(res.body)
+}
+
+func (s *Server) ServeFastHTTP(ctx *fasthttp.RequestCtx) {
+	query, _ := url.ParseQuery(string(ctx.QueryArgs().QueryString()))
+	res := s.serve(string(ctx.Method()), parseQuery(query), ctx.PostBody())
+	if res.contentType != "" {
+		ctx.SetContentType(res.contentType)
+	}
+	ctx.SetStatusCode(res.status)
+	ctx.SetBody(res.body)
+}
+
+type response struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+func parseQuery(q url.Values) *ReqNotify {
+	return &ReqNotify{
+		Signature:    q.Get("signature"),
+		MsgSignature: q.Get("msg_signature"),
+		Timestamp:    q.Get("timestamp"),
+		Nonce:        q.Get("nonce"),
+		Echostr:      q.Get("echostr"),
+		EncryptType:  q.Get("encrypt_type"),
+		Openid:       q.Get("openid"),
+	}
+}
+
+func (s *Server) serve(method string, p *ReqNotify, body []byte) *response {
+	c, err := App(s.appid)
+	if err != nil {
+		return textResponse(http.StatusInternalServerError, err.Error())
+	}
+	switch method {
+	case http.MethodGet:
+		echo, err := c.VerifyURL(p)
+		if err != nil {
+			c.Logger().Errorf("[%s] verify url failed: %s", c.Appid(), err.Error())
+			return textResponse(http.StatusForbidden, err.Error())
+		}
+		return textResponse(http.StatusOK, echo)
+	case http.MethodPost:
+		msg, err := c.Decode(p, body)
+		if err != nil {
+			c.Logger().Errorf("[%s] decode message failed: %s", c.Appid(), err.Error())
+			return textResponse(http.StatusForbidden, err.Error())
+		}
+		msg.server = s
+		if s.dedupWindow <= 0 {
+			return s.dispatch(c, msg)
+		}
+		key := zwx.PrefixNotify.Key(c.Appid(), msg.DedupKey())
+		if !c.Storage().SetNX(key, "", s.dedupWindow) {
+			c.Logger().Debugf("[%s] duplicate notify %s", c.Appid(), msg.DedupKey())
+			if cached := c.Storage().Get(key); cached != "" {
+				return replyResponse(msg, []byte(cached))
+			}
+			return c.noReply()
+		}
+		res := s.dispatch(c, msg)
+		switch {
+		case res.status != http.StatusOK:
+			// 未处理，允许微信重试
+			c.Storage().Del(key)
+		case res.contentType != textContentType:
+			c.Storage().SetEX(key, string(res.body), s.dedupWindow)
+		}
+		return res
+	default:
+		return textResponse(http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// releaseDedup 删除消息的去重标记，用于后台处理失败后允许微信重试推送时再次处理
+func (msg *Message) releaseDedup() {
+	if msg.server == nil || msg.server.dedupWindow <= 0 {
+		return
+	}
+	msg.ctx.Storage().Del(zwx.PrefixNotify.Key(msg.ctx.Appid(), msg.DedupKey()))
+}
+
+func (s *Server) dispatch(c *Context, msg *Message) *response {
+	if s.async != nil {
+		return s.dispatchAsync(c, msg)
+	}
+	return s.render(c, msg, s.dispatcher(msg))
+}
+
+// render 按推送模式生成被动回复
+func (s *Server) render(c *Context, msg *Message, reply Reply) *response {
+	if isNilReply(reply) {
+		return c.noReply()
+	}
+	var d []byte
+	jr, isJson := reply.(JsonReply)
+	if msg.IsJson() && !isJson {
+		c.Logger().Errorf("[%s] reply %T does not implement JsonReply, skip reply to json notify", c.Appid(), reply)
+		return c.noReply()
+	}
+	switch {
+	case msg.IsJson() && !msg.IsEncrypted():
+		d = jr.PlainJson()
+	case msg.IsJson():
+		send := jr.EncryptedJson()
+		if send == nil {
+			return c.noReply()
+		}
+		d, _ = sonic.Marshal(send)
+	case !msg.IsEncrypted():
+		d = reply.Plain()
+	default:
+		send := reply.Encrypted()
+		if send == nil {
+			return c.noReply()
+		}
+		d, _ = xml.Marshal(send)
+	}
+	return replyResponse(msg, d)
+}
+
+// VerifyURL
+// @Description: 回调URL验证，企业微信解密echostr，公众号、小程序校验signature后原样返回echostr
+// @receiver c
+// @param p
+// @return string
+// @return error
+func (c *Context) VerifyURL(p *ReqNotify) (string, error) {
+	if c.IsWork() {
+		cpt := c.crypt()
+		echo, err := cpt.VerifyURL(p.MsgSignature, p.Timestamp, p.Nonce, p.Echostr)
+		if err != nil {
+			return "", err
+		}
+		return string(echo), nil
+	}
+	if !c.CheckSignature(p) {
+		return "", errors.New("signature not equal")
+	}
+	return p.Echostr, nil
+}
+
+// CheckSignature
+// @Description: 校验明文signature，sha1(sort(token, timestamp, nonce))
+// @receiver c
+// @param p
+// @return bool
+func (c *Context) CheckSignature(p *ReqNotify) bool {
+	token, _ := c.notifyKeys()
+	return p.Signature != "" && utils.Signature(token, p.Timestamp, p.Nonce) == p.Signature
+}
+
+// noReply 不回复时公众号、小程序需返回success，企业微信返回空包
+func (c *Context) noReply() *response {
+	if c.IsWork() {
+		return textResponse(http.StatusOK, "")
+	}
+	return textResponse(http.StatusOK, "success")
+}
+
+const textContentType = "text/plain; charset=utf-8"
+
+// replyResponse 被动回复与推送格式一致
+func replyResponse(msg *Message, body []byte) *response {
+	if msg.IsJson() {
+		return &response{status: http.StatusOK, contentType: "application/json; charset=utf-8", body: body}
+	}
+	return &response{status: http.StatusOK, contentType: "application/xml; charset=utf-8", body: body}
+}
+
+func textResponse(status int, body string) *response {
+	return &response{status: status, contentType: textContentType, body: []byte(body)}
+}
+
+func isNilReply(r Reply) bool {
+	if r == nil {
+		return true
+	}
+	v := reflect.ValueOf(r)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
@@ -0,0 +1,94 @@
+package wxnotify
+
+import (
+	"github.com/zohu/zwx/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		msgId      int64
+		event      MessageEvent
+		infoType   MessageEvent
+		authorizer string
+		want       string
+	}{
+		{name: "message by msgid", msgId: 42, want: "42"},
+		{name: "event by sender time and event", event: MessageEventSubscribe, want: "u1:100:subscribe"},
+		{name: "component by authorizer time and info type", infoType: MessageEventAuthorized, authorizer: "wxa", want: "wxa:100:authorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := new(Message)
+			msg.FromUserName = "u1"
+			msg.CreateTime = 100
+			msg.MsgId = tt.msgId
+			msg.Event = tt.event
+			msg.InfoType = tt.infoType
+			msg.AuthorizerAppid = tt.authorizer
+			if got := msg.DedupKey(); got != tt.want {
+				t.Errorf("DedupKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// postPlain 按明文模式签名后推送
+func postPlain(s *Server, body string) *httptest.ResponseRecorder {
+	q := url.Values{}
+	q.Set("timestamp", "1700000000")
+	q.Set("nonce", "nonce")
+	q.Set("signature", utils.Signature(testToken, "1700000000", "nonce"))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notify?"+q.Encode(), strings.NewReader(body)))
+	return w
+}
+
+func TestServerDedup(t *testing.T) {
+	text := `<xml><ToUserName>gh_1</ToUserName><FromUserName>u1</FromUserName><CreateTime>100</CreateTime><MsgType>text</MsgType><Content>hi</Content><MsgId>42</MsgId></xml>`
+	event := `<xml><ToUserName>gh_1</ToUserName><FromUserName>u1</FromUserName><CreateTime>100</CreateTime><MsgType>event</MsgType><Event>unsubscribe</Event></xml>`
+	tests := []struct {
+		name      string
+		window    time.Duration
+		body      string
+		reply     bool
+		wantCalls int
+	}{
+		{"replay cached reply", DefaultDedupWindow, text, true, 1},
+		{"replay without reply", DefaultDedupWindow, event, false, 1},
+		{"disabled", 0, text, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			calls := 0
+			s := NewServer("wxmp", func(msg *Message) Reply {
+				calls++
+				if !tt.reply {
+					return nil
+				}
+				return msg.ReplyText("reply " + strconv.Itoa(calls))
+			}).Dedup(tt.window)
+			first := postPlain(s, tt.body)
+			for i := 0; i < 2; i++ {
+				w := postPlain(s, tt.body)
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+				}
+				if tt.window > 0 && w.Body.String() != first.Body.String() {
+					t.Errorf("replay = %s, want %s", w.Body.String(), first.Body.String())
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("dispatcher calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package wxnotify
+
+type TemplateCardType string
+
+// 模板卡片类型
+const (
+	TemplateCardTextNotice          TemplateCardType = "text_notice"          // 文本通知型
+	TemplateCardNewsNotice          TemplateCardType = "news_notice"          // 图文展示型
+	TemplateCardButtonInteraction   TemplateCardType = "button_interaction"   // 按钮交互型
+	TemplateCardVoteInteraction     TemplateCardType = "vote_interaction"     // 投票选择型
+	TemplateCardMultipleInteraction TemplateCardType = "multiple_interaction" // 多项选择型
+)
+
+// ReplyButton
+// @Description: 更新点击用户的按钮文案
+type ReplyButton struct {
+	ReplaceName string `json:"ReplaceName" xml:"ReplaceName"` // 替换后的按钮文案
+}
+
+// ReplyTemplateCard
+// @Description: 更新点击用户的整张卡片，字段按CardType选用：
+// text_notice: Source、ActionMenu、MainTitle、QuoteArea、EmphasisContent、SubTitleText、HorizontalContentList、JumpList、CardAction；
+// news_notice: 在text_notice基础上增加ImageTextArea、CardImage、VerticalContentList，不含EmphasisContent、SubTitleText；
+// button_interaction: Source、ActionMenu、TaskId、MainTitle、QuoteArea、SubTitleText、HorizontalContentList、CardAction、ButtonSelection、ButtonList、ReplaceText；
+// vote_interaction: Source、TaskId、MainTitle、CheckBox、SubmitButton、ReplaceText；
+// multiple_interaction: Source、TaskId、MainTitle、SelectList、SubmitButton、ReplaceText
+type ReplyTemplateCard struct {
+	CardType              TemplateCardType         `json:"CardType" xml:"CardType"`
+	Source                *CardSource              `json:"Source,omitempty" xml:"Source,omitempty"`                               // 卡片来源
+	ActionMenu            *CardActionMenu          `json:"ActionMenu,omitempty" xml:"ActionMenu,omitempty"`                       // 右上角菜单
+	TaskId                string                   `json:"TaskId,omitempty" xml:"TaskId,omitempty"`                               // 任务id，交互型卡片必填
+	MainTitle             *CardTitle               `json:"MainTitle,omitempty" xml:"MainTitle,omitempty"`                         // 一级标题
+	QuoteArea             *CardQuoteArea           `json:"QuoteArea,omitempty" xml:"QuoteArea,omitempty"`                         // 引用文献
+	EmphasisContent       *CardTitle               `json:"EmphasisContent,omitempty" xml:"EmphasisContent,omitempty"`             // 关键数据
+	SubTitleText          string                   `json:"SubTitleText,omitempty" xml:"SubTitleText,omitempty"`                   // 二级普通文本
+	ImageTextArea         *CardImageTextArea       `json:"ImageTextArea,omitempty" xml:"ImageTextArea,omitempty"`                 // 左图右文
+	CardImage             *CardImage               `json:"CardImage,omitempty" xml:"CardImage,omitempty"`                         // 图片
+	VerticalContentList   []*CardTitle             `json:"VerticalContentList,omitempty" xml:"VerticalContentList,omitempty"`     // 卡片二级垂直内容，最多4个
+	HorizontalContentList []*CardHorizontalContent `json:"HorizontalContentList,omitempty" xml:"HorizontalContentList,omitempty"` // 二级标题+文本，最多6个
+	JumpList              []*CardJump              `json:"JumpList,omitempty" xml:"JumpList,omitempty"`                           // 跳转指引，最多3个
+	CardAction            *CardJump                `json:"CardAction,omitempty" xml:"CardAction,omitempty"`                       // 整体卡片的点击跳转
+	ButtonSelection       *CardSelect              `json:"ButtonSelection,omitempty" xml:"ButtonSelection,omitempty"`             // 下拉式选择器
+	ButtonList            []*CardButton            `json:"ButtonList,omitempty" xml:"ButtonList,omitempty"`                       // 按钮，最多6个
+	CheckBox              *CardCheckBox            `json:"CheckBox,omitempty" xml:"CheckBox,omitempty"`                           // 投票选择
+	SelectList            []*CardSelect            `json:"SelectList,omitempty" xml:"SelectList,omitempty"`                       // 下拉式选择器列表，最多3个
+	SubmitButton          *CardButton              `json:"SubmitButton,omitempty" xml:"SubmitButton,omitempty"`                   // 提交按钮
+	ReplaceText           string                   `json:"ReplaceText,omitempty" xml:"ReplaceText,omitempty"`                     // 按钮替换文案
+}
+
+type CardSource struct {
+	IconUrl   string `json:"IconUrl,omitempty" xml:"IconUrl,omitempty"`
+	Desc      string `json:"Desc,omitempty" xml:"Desc,omitempty"`
+	DescColor int    `json:"DescColor,omitempty" xml:"DescColor,omitempty"` // 0灰色，1黑色，2红色，3绿色
+}
+type CardActionMenu struct {
+	Desc       string            `json:"Desc,omitempty" xml:"Desc,omitempty"`
+	ActionList []*CardActionItem `json:"ActionList" xml:"ActionList"` // 最多3个
+}
+type CardActionItem struct {
+	Text string `json:"Text" xml:"Text"`
+	Key  string `json:"Key" xml:"Key"`
+}
+type CardTitle struct {
+	Title string `json:"Title,omitempty" xml:"Title,omitempty"`
+	Desc  string `json:"Desc,omitempty" xml:"Desc,omitempty"`
+}
+type CardQuoteArea struct {
+	Type      int    `json:"Type,omitempty" xml:"Type,omitempty"` // 0无跳转，1跳转url，2跳转小程序
+	Url       string `json:"Url,omitempty" xml:"Url,omitempty"`
+	AppId     string `json:"AppId,omitempty" xml:"AppId,omitempty"`
+	PagePath  string `json:"PagePath,omitempty" xml:"PagePath,omitempty"`
+	Title     string `json:"Title,omitempty" xml:"Title,omitempty"`
+	QuoteText string `json:"QuoteText,omitempty" xml:"QuoteText,omitempty"`
+}
+type CardImageTextArea struct {
+	Type     int    `json:"Type,omitempty" xml:"Type,omitempty"` // 0无跳转，1跳转url，2跳转小程序
+	Url      string `json:"Url,omitempty" xml:"Url,omitempty"`
+	AppId    string `json:"AppId,omitempty" xml:"AppId,omitempty"`
+	PagePath string `json:"PagePath,omitempty" xml:"PagePath,omitempty"`
+	Title    string `json:"Title,omitempty" xml:"Title,omitempty"`
+	Desc     string `json:"Desc,omitempty" xml:"Desc,omitempty"`
+	ImageUrl string `json:"ImageUrl" xml:"ImageUrl"`
+}
+type CardImage struct {
+	Url         string  `json:"Url" xml:"Url"`
+	AspectRatio float64 `json:"AspectRatio,omitempty" xml:"AspectRatio,omitempty"` // 宽高比，1.3~2.25，默认1.3
+}
+type CardHorizontalContent struct {
+	KeyName string `json:"KeyName" xml:"KeyName"`
+	Value   string `json:"Value,omitempty" xml:"Value,omitempty"`
+	Type    int    `json:"Type,omitempty" xml:"Type,omitempty"` // 1跳转url，2下载附件，3点击跳转成员详情
+	Url     string `json:"Url,omitempty" xml:"Url,omitempty"`
+	MediaId string `json:"MediaId,omitempty" xml:"MediaId,omitempty"`
+	UserId  string `json:"UserId,omitempty" xml:"UserId,omitempty"`
+}
+type CardJump struct {
+	Type     int    `json:"Type" xml:"Type"` // 0无跳转，1跳转url，2跳转小程序
+	Title    string `json:"Title,omitempty" xml:"Title,omitempty"`
+	Url      string `json:"Url,omitempty" xml:"Url,omitempty"`
+	AppId    string `json:"AppId,omitempty" xml:"AppId,omitempty"`
+	PagePath string `json:"PagePath,omitempty" xml:"PagePath,omitempty"`
+}
+type CardSelect struct {
+	QuestionKey string        `json:"QuestionKey" xml:"QuestionKey"`
+	Title       string        `json:"Title,omitempty" xml:"Title,omitempty"`
+	SelectedId  string        `json:"SelectedId,omitempty" xml:"SelectedId,omitempty"`
+	Disable     bool          `json:"Disable,omitempty" xml:"Disable,omitempty"`
+	OptionList  []*CardOption `json:"OptionList" xml:"OptionList"`
+}
+type CardOption struct {
+	Id        string `json:"Id" xml:"Id"`
+	Text      string `json:"Text" xml:"Text"`
+	IsChecked bool   `json:"IsChecked,omitempty" xml:"IsChecked,omitempty"` // 投票选择型，是否默认选中
+}
+type CardCheckBox struct {
+	QuestionKey string        `json:"QuestionKey" xml:"QuestionKey"`
+	OptionList  []*CardOption `json:"OptionList" xml:"OptionList"`
+	Disable     bool          `json:"Disable,omitempty" xml:"Disable,omitempty"`
+	Mode        int           `json:"Mode,omitempty" xml:"Mode,omitempty"` // 0单选，1多选
+}
+type CardButton struct {
+	Text  string `json:"Text" xml:"Text"`
+	Style int    `json:"Style,omitempty" xml:"Style,omitempty"` // 1~4，默认1
+	Key   string `json:"Key" xml:"Key"`
+}
@@ -0,0 +1,303 @@
+package wxnotify
+
+// TypedMessage
+// @Description: 强类型消息，通过msg.Typed()获取，可用于type switch，未识别的消息为*UnknownMessage
+type TypedMessage interface {
+	Base() *MessageBase
+}
+
+// MessageBase
+// @Description: 强类型消息的公共字段
+type MessageBase struct {
+	ToUserName   string       `json:"ToUserName" xml:"ToUserName"`
+	FromUserName string       `json:"FromUserName" xml:"FromUserName"`
+	CreateTime   int64        `json:"CreateTime" xml:"CreateTime"`
+	MsgType      MessageType  `json:"MsgType" xml:"MsgType"`
+	Event        MessageEvent `json:"Event,omitempty" xml:"Event,omitempty"`
+	raw          []byte
+}
+
+func (b *MessageBase) Base() *MessageBase {
+	return b
+}
+
+// Raw 原始消息体
+func (b *MessageBase) Raw() []byte {
+	return b.raw
+}
+
+// -------------------------------普通消息-------------------------------
+
+type TextMessage struct {
+	MessageBase
+	MsgId   int64  `json:"MsgId" xml:"MsgId"`
+	Content string `json:"Content" xml:"Content"`
+}
+type ImageMessage struct {
+	MessageBase
+	MsgId   int64  `json:"MsgId" xml:"MsgId"`
+	PicUrl  string `json:"PicUrl" xml:"PicUrl"`
+	MediaId string `json:"MediaId" xml:"MediaId"`
+}
+type VoiceMessage struct {
+	MessageBase
+	MsgId       int64  `json:"MsgId" xml:"MsgId"`
+	MediaId     string `json:"MediaId" xml:"MediaId"`
+	Format      string `json:"Format" xml:"Format"`
+	Recognition string `json:"Recognition,omitempty" xml:"Recognition,omitempty"`
+}
+
+// VideoMessage 视频消息、小视频消息
+type VideoMessage struct {
+	MessageBase
+	MsgId        int64  `json:"MsgId" xml:"MsgId"`
+	MediaId      string `json:"MediaId" xml:"MediaId"`
+	ThumbMediaId string `json:"ThumbMediaId" xml:"ThumbMediaId"`
+}
+type LocationMessage struct {
+	MessageBase
+	MsgId     int64   `json:"MsgId" xml:"MsgId"`
+	LocationX float64 `json:"Location_X" xml:"Location_X"`
+	LocationY float64 `json:"Location_Y" xml:"Location_Y"`
+	Scale     int64   `json:"Scale" xml:"Scale"`
+	Label     string  `json:"Label" xml:"Label"`
+}
+type LinkMessage struct {
+	MessageBase
+	MsgId       int64  `json:"MsgId" xml:"MsgId"`
+	Title       string `json:"Title" xml:"Title"`
+	Description string `json:"Description" xml:"Description"`
+	Url         string `json:"Url" xml:"Url"`
+}
+type MiniProgramPageMessage struct {
+	MessageBase
+	MsgId        int64  `json:"MsgId" xml:"MsgId"`
+	Title        string `json:"Title" xml:"Title"`
+	AppId        string `json:"AppId" xml:"AppId"`
+	PagePath     string `json:"PagePath" xml:"PagePath"`
+	ThumbUrl     string `json:"ThumbUrl" xml:"ThumbUrl"`
+	ThumbMediaId string `json:"ThumbMediaId" xml:"ThumbMediaId"`
+}
+
+// -------------------------------公众号事件-------------------------------
+
+// SubscribeEvent 关注事件，扫描带参二维码关注时EventKey为qrscene_前缀的场景值
+type SubscribeEvent struct {
+	MessageBase
+	EventKey string `json:"EventKey,omitempty" xml:"EventKey,omitempty"`
+	Ticket   string `json:"Ticket,omitempty" xml:"Ticket,omitempty"`
+}
+type UnsubscribeEvent struct {
+	MessageBase
+}
+
+// ScanEvent 已关注用户扫描带参二维码
+type ScanEvent struct {
+	MessageBase
+	EventKey string `json:"EventKey" xml:"EventKey"`
+	Ticket   string `json:"Ticket" xml:"Ticket"`
+}
+type LocationEvent struct {
+	MessageBase
+	Latitude  float64 `json:"Latitude" xml:"Latitude"`
+	Longitude float64 `json:"Longitude" xml:"Longitude"`
+	Precision float64 `json:"Precision" xml:"Precision"`
+}
+
+// ClickEvent 点击菜单拉取消息
+type ClickEvent struct {
+	MessageBase
+	EventKey string `json:"EventKey" xml:"EventKey"`
+	AgentID  int64  `json:"AgentID,omitempty" xml:"AgentID,omitempty"`
+}
+
+// ViewEvent 点击菜单跳转链接，EventKey为链接地址
+type ViewEvent struct {
+	MessageBase
+	EventKey string `json:"EventKey" xml:"EventKey"`
+	MenuId   string `json:"MenuId,omitempty" xml:"MenuId,omitempty"`
+	AgentID  int64  `json:"AgentID,omitempty" xml:"AgentID,omitempty"`
+}
+
+// ScancodeEvent 扫码推事件、扫码推事件且弹出“消息接收中”提示框
+type ScancodeEvent struct {
+	MessageBase
+	EventKey     string        `json:"EventKey" xml:"EventKey"`
+	ScanCodeInfo *ScanCodeInfo `json:"ScanCodeInfo" xml:"ScanCodeInfo"`
+	AgentID      int64         `json:"AgentID,omitempty" xml:"AgentID,omitempty"`
+}
+type TemplateSendJobFinishEvent struct {
+	MessageBase
+	MsgID  int64  `json:"MsgID" xml:"MsgID"`
+	Status string `json:"Status" xml:"Status"` // success、failed:user block、failed: system failed
+}
+type MassSendJobFinishEvent struct {
+	MessageBase
+	MsgID       int64  `json:"MsgID" xml:"MsgID"`
+	Status      string `json:"Status" xml:"Status"`
+	TotalCount  int64  `json:"TotalCount" xml:"TotalCount"`
+	FilterCount int64  `json:"FilterCount" xml:"FilterCount"`
+	SentCount   int64  `json:"SentCount" xml:"SentCount"`
+	ErrorCount  int64  `json:"ErrorCount" xml:"ErrorCount"`
+}
+type SubscribeMsgPopupEventMessage struct {
+	MessageBase
+	SubscribeMsgPopupEvent
+}
+type SubscribeMsgChangeEventMessage struct {
+	MessageBase
+	SubscribeMsgChangeEvent
+}
+type SubscribeMsgSentEventMessage struct {
+	MessageBase
+	SubscribeMsgSentEvent
+}
+
+// -------------------------------企业微信事件-------------------------------
+
+// ChangeContactEvent 通讯录变更，按ChangeType区分成员、部门、标签变更
+type ChangeContactEvent struct {
+	MessageBase
+	ChangeType string `json:"ChangeType" xml:"ChangeType"`
+	// 成员
+	UserID         string `json:"UserID,omitempty" xml:"UserID,omitempty"`
+	NewUserID      string `json:"NewUserID,omitempty" xml:"NewUserID,omitempty"`
+	Department     string `json:"Department,omitempty" xml:"Department,omitempty"`
+	MainDepartment string `json:"MainDepartment,omitempty" xml:"MainDepartment,omitempty"`
+	IsLeaderInDept string `json:"IsLeaderInDept,omitempty" xml:"IsLeaderInDept,omitempty"`
+	DirectLeader   string `json:"DirectLeader,omitempty" xml:"DirectLeader,omitempty"`
+	Position       string `json:"Position,omitempty" xml:"Position,omitempty"`
+	Mobile         string `json:"Mobile,omitempty" xml:"Mobile,omitempty"`
+	Gender         int64  `json:"Gender,omitempty" xml:"Gender,omitempty"`
+	Email          string `json:"Email,omitempty" xml:"Email,omitempty"`
+	BizMail        string `json:"BizMail,omitempty" xml:"BizMail,omitempty"`
+	Status         int64  `json:"Status,omitempty" xml:"Status,omitempty"`
+	Avatar         string `json:"Avatar,omitempty" xml:"Avatar,omitempty"`
+	Alias          string `json:"Alias,omitempty" xml:"Alias,omitempty"`
+	Telephone      string `json:"Telephone,omitempty" xml:"Telephone,omitempty"`
+	Address        string `json:"Address,omitempty" xml:"Address,omitempty"`
+	// 成员名称或部门名称
+	Name string `json:"Name,omitempty" xml:"Name,omitempty"`
+	// 部门
+	Id       int64 `json:"Id,omitempty" xml:"Id,omitempty"`
+	ParentId int64 `json:"ParentId,omitempty" xml:"ParentId,omitempty"`
+	Order    int64 `json:"Order,omitempty" xml:"Order,omitempty"`
+	// 标签
+	TagId         int64  `json:"TagId,omitempty" xml:"TagId,omitempty"`
+	AddUserItems  string `json:"AddUserItems,omitempty" xml:"AddUserItems,omitempty"`
+	DelUserItems  string `json:"DelUserItems,omitempty" xml:"DelUserItems,omitempty"`
+	AddPartyItems string `json:"AddPartyItems,omitempty" xml:"AddPartyItems,omitempty"`
+	DelPartyItems string `json:"DelPartyItems,omitempty" xml:"DelPartyItems,omitempty"`
+}
+
+// ApprovalChangeEvent 审批状态通知
+type ApprovalChangeEvent struct {
+	MessageBase
+	AgentID      int64         `json:"AgentID" xml:"AgentID"`
+	ApprovalInfo *ApprovalInfo `json:"ApprovalInfo" xml:"ApprovalInfo"`
+}
+type EnterAgentEvent struct {
+	MessageBase
+	EventKey string `json:"EventKey" xml:"EventKey"`
+	AgentID  int64  `json:"AgentID" xml:"AgentID"`
+}
+type BatchJobResultEvent struct {
+	MessageBase
+	BatchJob *BatchJob `json:"BatchJob" xml:"BatchJob"`
+}
+type TemplateCardEvent struct {
+	MessageBase
+	EventKey string `json:"EventKey" xml:"EventKey"`
+	AgentID  int64  `json:"AgentID" xml:"AgentID"`
+	TemplateCard
+}
+
+// UnknownMessage 未识别的消息或事件，可通过Raw()自行解析
+type UnknownMessage struct {
+	MessageBase
+}
+
+// Typed
+// @Description: 按MsgType、Event转换为强类型消息
+// @receiver msg
+// @return TypedMessage
+func (msg *Message) Typed() TypedMessage {
+	var v TypedMessage
+	switch msg.MsgType {
+	case MessageTypeText:
+		v = new(TextMessage)
+	case MessageTypeImage:
+		v = new(ImageMessage)
+	case MessageTypeVoice:
+		v = new(VoiceMessage)
+	case MessageTypeVideo, MessageTypeShortvideo:
+		v = new(VideoMessage)
+	case MessageTypeLocation:
+		v = new(LocationMessage)
+	case MessageTypeLink:
+		v = new(LinkMessage)
+	case MessageTypeMiniprogrampage:
+		v = new(MiniProgramPageMessage)
+	case MessageTypeEvent:
+		v = msg.typedEvent()
+	}
+	if v == nil {
+		v = new(UnknownMessage)
+	}
+	if err := unmarshal(msg.json, msg.raw, v); err != nil {
+		msg.logger().Errorf("[%s] typed message %s/%s failed: %s", msg.appid(), msg.MsgType, msg.Event, err.Error())
+		v = new(UnknownMessage)
+		_ = unmarshal(msg.json, msg.raw, v)
+	}
+	// JSON格式的订阅通知列表已在解析Message时处理
+	switch e := v.(type) {
+	case *SubscribeMsgPopupEventMessage:
+		e.SubscribeMsgPopupEvent = msg.SubscribeMsgPopupEvent
+	case *SubscribeMsgChangeEventMessage:
+		e.SubscribeMsgChangeEvent = msg.SubscribeMsgChangeEvent
+	case *SubscribeMsgSentEventMessage:
+		e.SubscribeMsgSentEvent = msg.SubscribeMsgSentEvent
+	}
+	v.Base().raw = msg.raw
+	return v
+}
+
+func (msg *Message) typedEvent() TypedMessage {
+	switch msg.Event.canonical() {
+	case MessageEventSubscribe:
+		return new(SubscribeEvent)
+	case MessageEventUnsubscribe:
+		return new(UnsubscribeEvent)
+	case MessageEventScan:
+		return new(ScanEvent)
+	case MessageEventLocation:
+		return new(LocationEvent)
+	case MessageEventClick:
+		return new(ClickEvent)
+	case MessageEventView:
+		return new(ViewEvent)
+	case MessageEventScancodePush, MessageEventScancodeWaitmsg:
+		return new(ScancodeEvent)
+	case MessageEventTemplateSendJobFinish:
+		return new(TemplateSendJobFinishEvent)
+	case MessageEventMassSendJobFinish:
+		return new(MassSendJobFinishEvent)
+	case MessageEventSubscribeMsgPopupEvent:
+		return new(SubscribeMsgPopupEventMessage)
+	case MessageEventSubscribeMsgChangeEvent:
+		return new(SubscribeMsgChangeEventMessage)
+	case MessageEventSubscribeMsgSentEvent:
+		return new(SubscribeMsgSentEventMessage)
+	case MessageWorkTypeChangeContact:
+		return new(ChangeContactEvent)
+	case MessageEventOpenApprovalChange:
+		return new(ApprovalChangeEvent)
+	case MessageEventEnterAgent:
+		return new(EnterAgentEvent)
+	case MessageEventBatchJobResult:
+		return new(BatchJobResultEvent)
+	case MessageEventTemplateCardEvent:
+		return new(TemplateCardEvent)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package wxnotify
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTyped(t *testing.T) {
+	setupTest(t)
+	tests := []struct {
+		name     string
+		appid    string
+		data     string
+		wantType string
+		check    func(v TypedMessage) bool
+	}{
+		{
+			name:     "text",
+			appid:    "wxmp",
+			data:     `<xml><FromUserName>u1</FromUserName><MsgType>text</MsgType><Content>hi</Content><MsgId>42</MsgId></xml>`,
+			wantType: "*wxnotify.TextMessage",
+			check:    func(v TypedMessage) bool { m := v.(*TextMessage); return m.Content == "hi" && m.MsgId == 42 },
+		},
+		{
+			name:     "shortvideo as video",
+			appid:    "wxmp",
+			data:     `<xml><MsgType>shortvideo</MsgType><MediaId>m1</MediaId><ThumbMediaId>t1</ThumbMediaId></xml>`,
+			wantType: "*wxnotify.VideoMessage",
+			check:    func(v TypedMessage) bool { return v.(*VideoMessage).ThumbMediaId == "t1" },
+		},
+		{
+			name:     "subscribe with scene",
+			appid:    "wxmp",
+			data:     `<xml><MsgType>event</MsgType><Event>subscribe</Event><EventKey>qrscene_123</EventKey><Ticket>tk</Ticket></xml>`,
+			wantType: "*wxnotify.SubscribeEvent",
+			check:    func(v TypedMessage) bool { return v.(*SubscribeEvent).EventKey == "qrscene_123" },
+		},
+		{
+			name:     "scan",
+			appid:    "wxmp",
+			data:     `<xml><MsgType>event</MsgType><Event>SCAN</Event><EventKey>123</EventKey></xml>`,
+			wantType: "*wxnotify.ScanEvent",
+			check:    func(v TypedMessage) bool { return v.(*ScanEvent).EventKey == "123" },
+		},
+		{
+			name:     "mp click",
+			appid:    "wxmp",
+			data:     `<xml><MsgType>event</MsgType><Event>CLICK</Event><EventKey>MENU_1</EventKey></xml>`,
+			wantType: "*wxnotify.ClickEvent",
+			check:    func(v TypedMessage) bool { return v.(*ClickEvent).EventKey == "MENU_1" },
+		},
+		{
+			name:     "work click",
+			appid:    "corp1",
+			data:     `<xml><MsgType>event</MsgType><Event>click</Event><EventKey>MENU_1</EventKey><AgentID>1000002</AgentID></xml>`,
+			wantType: "*wxnotify.ClickEvent",
+			check:    func(v TypedMessage) bool { return v.(*ClickEvent).AgentID == 1000002 },
+		},
+		{
+			name:     "template send job finish",
+			appid:    "wxmp",
+			data:     `<xml><MsgType>event</MsgType><Event>TEMPLATESENDJOBFINISH</Event><MsgID>7</MsgID><Status>success</Status></xml>`,
+			wantType: "*wxnotify.TemplateSendJobFinishEvent",
+			check:    func(v TypedMessage) bool { return v.(*TemplateSendJobFinishEvent).MsgID == 7 },
+		},
+		{
+			name:     "json popup list",
+			appid:    "wxmp",
+			data:     `{"MsgType":"event","Event":"subscribe_msg_popup_event","List":{"TemplateId":"t1","SubscribeStatusString":"accept","PopupScene":"2"}}`,
+			wantType: "*wxnotify.SubscribeMsgPopupEventMessage",
+			check: func(v TypedMessage) bool {
+				l := v.(*SubscribeMsgPopupEventMessage).SubscribeMsgPopupEvent.SubscribeMsgPopupEvent
+				return len(l) == 1 && l[0].PopupScene == 2
+			},
+		},
+		{
+			name:     "change contact",
+			appid:    "corp1",
+			data:     `<xml><MsgType>event</MsgType><Event>change_contact</Event><ChangeType>create_user</ChangeType><UserID>zhangsan</UserID></xml>`,
+			wantType: "*wxnotify.ChangeContactEvent",
+			check: func(v TypedMessage) bool {
+				m := v.(*ChangeContactEvent)
+				return m.ChangeType == "create_user" && m.UserID == "zhangsan"
+			},
+		},
+		{
+			name:     "unknown event keeps raw",
+			appid:    "wxmp",
+			data:     `<xml><MsgType>event</MsgType><Event>some_new_event</Event><Foo>bar</Foo></xml>`,
+			wantType: "*wxnotify.UnknownMessage",
+			check:    func(v TypedMessage) bool { return string(v.Base().Raw()) != "" && v.Base().Event == "some_new_event" },
+		},
+		{
+			name:     "unknown message type",
+			appid:    "wxmp",
+			data:     `<xml><MsgType>future</MsgType></xml>`,
+			wantType: "*wxnotify.UnknownMessage",
+			check:    func(v TypedMessage) bool { return v.Base().MsgType == "future" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := parseMessage(t, tt.appid, tt.data).Typed()
+			if got := fmt.Sprintf("%T", v); got != tt.wantType {
+				t.Fatalf("Typed() = %s, want %s", got, tt.wantType)
+			}
+			if !tt.check(v) {
+				t.Errorf("fields = %+v", v)
+			}
+			if string(v.Base().Raw()) != tt.data {
+				t.Errorf("raw = %s", v.Base().Raw())
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package wxopen
+
+import (
+	"github.com/zohu/zwx"
+)
+
+type Context struct {
+	*zwx.Context
+}
+
+func App(appid string) (*Context, error) {
+	c, err := zwx.LoadApp(appid)
+	if err != nil {
+		return nil, err
+	}
+	if !c.IsWxOpen() {
+		return nil, c.Error("", "this appid is not a open platform app")
+	}
+	return &Context{Context: c}, nil
+}
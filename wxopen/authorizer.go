@@ -0,0 +1,175 @@
+package wxopen
+
+import (
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/utils"
+	"time"
+)
+
+type AuthorizationInfo struct {
+	AuthorizerAppid        string `json:"authorizer_appid"`
+	AuthorizerAccessToken  string `json:"authorizer_access_token,omitempty"`
+	ExpiresIn              int    `json:"expires_in,omitempty"`
+	AuthorizerRefreshToken string `json:"authorizer_refresh_token"`
+	FuncInfo               []struct {
+		FuncscopeCategory struct {
+			Id int `json:"id"`
+		} `json:"funcscope_category"`
+	} `json:"func_info"`
+}
+type ResQueryAuth struct {
+	zwx.WxResponse
+	AuthorizationInfo AuthorizationInfo `json:"authorization_info"`
+}
+
+// QueryAuth
+// @Description: 使用授权码获取授权信息
+// @receiver c
+// @param code 授权回调或授权事件中的authorization_code
+// @return *AuthorizationInfo
+// @return error
+func (c *Context) QueryAuth(code string) (*AuthorizationInfo, error) {
+	var resp ResQueryAuth
+	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiCgiBin.WithPath("component/api_query_auth")).
+		SetQuery(map[string]string{
+			"component_access_token": c.AccessToken(),
+		}).
+		SetJson(map[string]string{
+			"component_appid":    c.Appid(),
+			"authorization_code": code,
+		}).
+		BindJson(&resp).
+		Debug(c.IsDebug(), c.Logger()).
+		Do(); err != nil {
+		return nil, c.ErrorWith("query_auth", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.QueryAuth(code)
+		}
+		return nil, c.ErrorFrom("query_auth", resp.WxResponse)
+	}
+	return &resp.AuthorizationInfo, nil
+}
+
+type AuthorizerInfo struct {
+	NickName        string `json:"nick_name"`
+	HeadImg         string `json:"head_img"`
+	ServiceTypeInfo struct {
+		Id int `json:"id"` // 公众号0、1订阅号，2服务号；小程序固定为0
+	} `json:"service_type_info"`
+	VerifyTypeInfo struct {
+		Id int `json:"id"`
+	} `json:"verify_type_info"`
+	UserName        string `json:"user_name"`
+	PrincipalName   string `json:"principal_name"`
+	Alias           string `json:"alias"`
+	QrcodeUrl       string `json:"qrcode_url"`
+	MiniProgramInfo *struct {
+		Network map[string][]string `json:"network"`
+	} `json:"MiniProgramInfo,omitempty"`
+}
+type ResAuthorizerInfo struct {
+	zwx.WxResponse
+	AuthorizerInfo    AuthorizerInfo    `json:"authorizer_info"`
+	AuthorizationInfo AuthorizationInfo `json:"authorization_info"`
+}
+
+// AppType 根据授权方信息判断应用类型
+func (r *ResAuthorizerInfo) AppType() zwx.AppType {
+	switch {
+	case r.AuthorizerInfo.MiniProgramInfo != nil:
+		return zwx.TypeWxMiniApp
+	case r.AuthorizerInfo.ServiceTypeInfo.Id == 2:
+		return zwx.TypeWxMpServe
+	default:
+		return zwx.TypeWxMpSubscribe
+	}
+}
+
+// AuthorizerInfo
+// @Description: 获取授权方的账号基本信息
+// @receiver c
+// @param authorizerAppid
+// @return *ResAuthorizerInfo
+// @return error
+func (c *Context) AuthorizerInfo(authorizerAppid string) (*ResAuthorizerInfo, error) {
+	var resp ResAuthorizerInfo
+	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiCgiBin.WithPath("component/api_get_authorizer_info")).
+		SetQuery(map[string]string{
+			"component_access_token": c.AccessToken(),
+		}).
+		SetJson(map[string]string{
+			"component_appid":  c.Appid(),
+			"authorizer_appid": authorizerAppid,
+		}).
+		BindJson(&resp).
+		Debug(c.IsDebug(), c.Logger()).
+		Do(); err != nil {
+		return nil, c.ErrorWith("get_authorizer_info", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.AuthorizerInfo(authorizerAppid)
+		}
+		return nil, c.ErrorFrom("get_authorizer_info", resp.WxResponse)
+	}
+	return &resp, nil
+}
+
+// Authorize
+// @Description: 使用授权码托管授权方，已托管时更新authorizer_refresh_token，之后由zwx通过第三方平台自动刷新其access_token
+// @receiver c
+// @param code
+// @return *zwx.App
+// @return error
+func (c *Context) Authorize(code string) (*zwx.App, error) {
+	auth, err := c.QueryAuth(code)
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.AuthorizerInfo(auth.AuthorizerAppid)
+	if err != nil {
+		return nil, err
+	}
+	app := zwx.App{
+		AppType:   info.AppType(),
+		Appid:     auth.AuthorizerAppid,
+		AppSecret: auth.AuthorizerRefreshToken,
+		// 授权方的消息推送至第三方平台的消息接收地址，加解密时使用第三方平台的token和key
+		ComponentAppid: c.Appid(),
+	}
+	// 授权时已返回authorizer_access_token，直接使用，无需再次请求
+	if auth.AuthorizerAccessToken != "" && auth.ExpiresIn > 0 {
+		app.AccessToken = auth.AuthorizerAccessToken
+		app.ExpireTime = utils.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	}
+	if old, e := zwx.LoadApp(app.Appid); e != nil {
+		err = zwx.CreateApp(app)
+	} else if old.ComponentAppid() != c.Appid() {
+		return nil, c.Error("authorize", "app "+app.Appid+" is not hosted by this component")
+	} else {
+		err = zwx.UpdateApp(app)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// Unauthorize
+// @Description: 授权方取消授权后停止托管，不属于该第三方平台的APP不处理
+// @receiver c
+// @param authorizerAppid
+// @return error
+func (c *Context) Unauthorize(authorizerAppid string) error {
+	old, err := zwx.LoadApp(authorizerAppid)
+	if err != nil {
+		return nil
+	}
+	if old.ComponentAppid() != c.Appid() {
+		return c.Error("unauthorize", "app "+authorizerAppid+" is not hosted by this component")
+	}
+	zwx.DeleteApp(authorizerAppid)
+	return nil
+}
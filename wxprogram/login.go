@@ -21,6 +21,7 @@ type ResCode2Session struct {
 func (c *Context) Code2Session(code string) (*ResCode2Session, error) {
 	var resp ResCode2Session
 	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiSns.WithPath("jscode2session")).
+		Idempotent(false).
 		SetQuery(map[string]string{
 			"appid":      c.Appid(),
 			"secret":     c.AppSecret(),
@@ -30,10 +31,10 @@ func (c *Context) Code2Session(code string) (*ResCode2Session, error) {
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("code2session", err.Error())
+		return nil, c.ErrorWith("code2session", err)
 	}
 	if resp.Errcode != 0 {
-		return nil, c.Error("code2session", resp.Errmsg)
+		return nil, c.ErrorFrom("code2session", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -48,7 +49,7 @@ func (c *Context) Code2Session(code string) (*ResCode2Session, error) {
 func (c *Context) CheckSessionKey(openid, sessionKey string) (*zwx.WxResponse, error) {
 	var resp zwx.WxResponse
 	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiWxa.WithPath("checksession")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetQuery(map[string]string{
 			"openid":     openid,
 			"signature":  wxcpt.HmacSha256ToBase64("", sessionKey),
@@ -57,13 +58,13 @@ func (c *Context) CheckSessionKey(openid, sessionKey string) (*zwx.WxResponse, e
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("checksession", err.Error())
+		return nil, c.ErrorWith("checksession", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.CheckSessionKey(openid, sessionKey)
 		}
-		return nil, c.Error("checksession", resp.Errmsg)
+		return nil, c.ErrorFrom("checksession", resp)
 	}
 	return &resp, nil
 }
@@ -84,7 +85,8 @@ type RespResetUserSessionKey struct {
 func (c *Context) ResetUserSessionKey(openid, sessionKey string) (*RespResetUserSessionKey, error) {
 	var resp RespResetUserSessionKey
 	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiWxa.WithPath("resetusersessionkey")).
-		SetAccessToken(c.AccessToken()).
+		Idempotent(false).
+		SetAccessTokenFrom(c.Context).
 		SetQuery(map[string]string{
 			"openid":     openid,
 			"signature":  wxcpt.HmacSha256ToBase64("", sessionKey),
@@ -93,13 +95,13 @@ func (c *Context) ResetUserSessionKey(openid, sessionKey string) (*RespResetUser
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("reset checksession", err.Error())
+		return nil, c.ErrorWith("reset checksession", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.ResetUserSessionKey(openid, sessionKey)
 		}
-		return nil, c.Error("reset checksession", resp.Errmsg)
+		return nil, c.ErrorFrom("reset checksession", resp.WxResponse)
 	}
 	return &resp, nil
 }
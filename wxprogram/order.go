@@ -2,6 +2,7 @@ package wxprogram
 
 import (
 	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/utils"
 	"time"
 )
 
@@ -27,7 +28,7 @@ type ParamUploadShippingInfo struct {
 func (c *Context) UploadShippingInfo(openid, itemName, tid string) error {
 	var resp zwx.WxResponse
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiWxa.WithPath("sec/order/upload_shipping_info")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetJson(&ParamUploadShippingInfo{
 			OrderKey: UploadShippingInfoOrderKey{
 				OrderNumberType: 2,
@@ -36,19 +37,19 @@ func (c *Context) UploadShippingInfo(openid, itemName, tid string) error {
 			LogisticsType: 4,
 			DeliveryMode:  1,
 			ShippingList:  []UploadShippingInfoShippingItem{{ItemDesc: itemName}},
-			UploadTime:    time.Now().Format(time.RFC3339),
+			UploadTime:    utils.Now().Format(time.RFC3339),
 			Payer:         UploadShippingInfoPayer{Openid: openid},
 		}).
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return c.Error("upload_shipping_info", err.Error())
+		return c.ErrorWith("upload_shipping_info", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.UploadShippingInfo(openid, itemName, tid)
 		}
-		return c.Error("upload_shipping_info", resp.Errmsg)
+		return c.ErrorFrom("upload_shipping_info", resp)
 	}
 	return nil
 }
@@ -29,18 +29,18 @@ type RespGetQRCode struct {
 func (c *Context) GetQRCode(req *ReqGetQRCode) (*RespGetQRCode, error) {
 	var resp RespGetQRCode
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiWxa.WithPath("getwxacode")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetJson(req).
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("get_qrcode", err.Error())
+		return nil, c.ErrorWith("get_qrcode", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.GetQRCode(req)
 		}
-		return nil, c.Error("get_qrcode", resp.Errmsg)
+		return nil, c.ErrorFrom("get_qrcode", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -65,18 +65,18 @@ type ReqGetUnlimitedQRCode struct {
 func (c *Context) GetUnlimitedQRCode(req *ReqGetUnlimitedQRCode) (*RespGetQRCode, error) {
 	var resp RespGetQRCode
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiWxa.WithPath("getwxacodeunlimit")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetJson(req).
 		BindJsonOrBytes(&resp, &resp.Buffer).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("get_limited_qrcode", err.Error())
+		return nil, c.ErrorWith("get_limited_qrcode", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.GetUnlimitedQRCode(req)
 		}
-		return nil, c.Error("get_limited_qrcode", resp.Errmsg)
+		return nil, c.ErrorFrom("get_limited_qrcode", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -95,18 +95,18 @@ type ReqCreateQRCode struct {
 func (c *Context) CreateQRCode(req *ReqCreateQRCode) (*RespGetQRCode, error) {
 	var resp RespGetQRCode
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiCgiBin.WithPath("wxaapp/createwxaqrcode")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetJson(req).
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("create_qrcode", err.Error())
+		return nil, c.ErrorWith("create_qrcode", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.CreateQRCode(req)
 		}
-		return nil, c.Error("create_qrcode", resp.Errmsg)
+		return nil, c.ErrorFrom("create_qrcode", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -124,18 +124,18 @@ type RespURLLink struct {
 func (c *Context) URLLink(req *ReqURLLink) (string, error) {
 	var resp RespURLLink
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiWxa.WithPath("generate_urllink")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetJson(req).
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return "", c.Error("generate_urllink", err.Error())
+		return "", c.ErrorWith("generate_urllink", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.URLLink(req)
 		}
-		return "", c.Error("generate_urllink", resp.Errmsg)
+		return "", c.ErrorFrom("generate_urllink", resp.WxResponse)
 	}
 	return resp.UrlLink, nil
 }
@@ -19,20 +19,20 @@ type RespGetPluginOpenPId struct {
 func (c *Context) GetPluginOpenPId(code string) (*RespGetPluginOpenPId, error) {
 	var resp RespGetPluginOpenPId
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiWxa.WithPath("plugin/get_open_pid")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetJson(map[string]string{
 			"code": code,
 		}).
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("get_plugin_open_pid", err.Error())
+		return nil, c.ErrorWith("get_plugin_open_pid", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.GetPluginOpenPId(code)
 		}
-		return nil, c.Error("get_plugin_open_pid", resp.Errmsg)
+		return nil, c.ErrorFrom("get_plugin_open_pid", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -52,20 +52,21 @@ type RespCheckEncryptedData struct {
 func (c *Context) CheckEncryptedData(encrypted string) (*RespCheckEncryptedData, error) {
 	var resp RespCheckEncryptedData
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiWxa.WithPath("business/checkencryptedmsg")).
-		SetAccessToken(c.AccessToken()).
+		Idempotent(true).
+		SetAccessTokenFrom(c.Context).
 		SetJson(map[string]string{
 			"encrypt_data": encrypted,
 		}).
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("check_encrypted_data", err.Error())
+		return nil, c.ErrorWith("check_encrypted_data", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.CheckEncryptedData(encrypted)
 		}
-		return nil, c.Error("check_encrypted_data", resp.Errmsg)
+		return nil, c.ErrorFrom("check_encrypted_data", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -90,7 +91,7 @@ type RespGetPaidUnionid struct {
 func (c *Context) GetPaidUnionid(req *ReqGetPaidUnionid) (*RespGetPaidUnionid, error) {
 	var resp RespGetPaidUnionid
 	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiWxa.WithPath("getpaidunionid")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetQuery(map[string]string{
 			"openid":         req.Openid,
 			"transaction_id": req.TransactionId,
@@ -100,13 +101,13 @@ func (c *Context) GetPaidUnionid(req *ReqGetPaidUnionid) (*RespGetPaidUnionid, e
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("get_paid_unionid", err.Error())
+		return nil, c.ErrorWith("get_paid_unionid", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.GetPaidUnionid(req)
 		}
-		return nil, c.Error("get_paid_unionid", resp.Errmsg)
+		return nil, c.ErrorFrom("get_paid_unionid", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -132,7 +133,7 @@ type RespGetUserEncryptKey struct {
 func (c *Context) GetUserEncryptKey(openid, sessionKey string) (*RespGetUserEncryptKey, error) {
 	var resp RespGetUserEncryptKey
 	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiWxa.WithPath("getuserencryptkey")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetQuery(map[string]string{
 			"openid":     openid,
 			"signature":  wxcpt.HmacSha256ToBase64("", sessionKey),
@@ -141,13 +142,13 @@ func (c *Context) GetUserEncryptKey(openid, sessionKey string) (*RespGetUserEncr
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("getuserencryptkey", err.Error())
+		return nil, c.ErrorWith("getuserencryptkey", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.GetUserEncryptKey(openid, sessionKey)
 		}
-		return nil, c.Error("getuserencryptkey", resp.Errmsg)
+		return nil, c.ErrorFrom("getuserencryptkey", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -175,7 +176,7 @@ type RespGetPhoneNumber struct {
 func (c *Context) GetPhoneNumber(code, openid string) (*RespGetPhoneNumber, error) {
 	var resp RespGetPhoneNumber
 	if err := zwx.NewHttp(zwx.MethodPost, zwx.ApiWxa.WithPath("business/getuserphonenumber")).
-		SetAccessToken(c.AccessToken()).
+		SetAccessTokenFrom(c.Context).
 		SetJson(map[string]string{
 			"code":   code,
 			"openid": openid,
@@ -183,13 +184,13 @@ func (c *Context) GetPhoneNumber(code, openid string) (*RespGetPhoneNumber, erro
 		BindJson(&resp).
 		Debug(c.IsDebug(), c.Logger()).
 		Do(); err != nil {
-		return nil, c.Error("get_phone_number", err.Error())
+		return nil, c.ErrorWith("get_phone_number", err)
 	}
 	if resp.Errcode != 0 {
 		if c.RetryAccessToken(resp.Errcode) {
 			return c.GetPhoneNumber(code, openid)
 		}
-		return nil, c.Error("get_phone_number", resp.Errmsg)
+		return nil, c.ErrorFrom("get_phone_number", resp.WxResponse)
 	}
 	return &resp, nil
 }
@@ -0,0 +1,20 @@
+package wxwork
+
+import (
+	"github.com/zohu/zwx"
+)
+
+type Context struct {
+	*zwx.Context
+}
+
+func App(appid string) (*Context, error) {
+	c, err := zwx.LoadApp(appid)
+	if err != nil {
+		return nil, err
+	}
+	if !c.IsWork() {
+		return nil, c.Error("", "非企业微信")
+	}
+	return &Context{Context: c}, nil
+}
@@ -0,0 +1,168 @@
+package wxwork
+
+import (
+	"github.com/zohu/zwx"
+	"strconv"
+)
+
+type Department struct {
+	Id               int64    `json:"id"`
+	Name             string   `json:"name"`
+	NameEn           string   `json:"name_en,omitempty"`
+	DepartmentLeader []string `json:"department_leader,omitempty"`
+	Parentid         int64    `json:"parentid"`
+	Order            int64    `json:"order"`
+}
+type ResDepartmentList struct {
+	zwx.WxResponse
+	Department []Department `json:"department"`
+}
+
+// DepartmentList
+// @Description: 获取部门列表
+// @receiver c
+// @param id 部门id，0时获取全量组织架构
+// @return []Department
+// @return error
+func (c *Context) DepartmentList(id int64) ([]Department, error) {
+	query := map[string]string{}
+	if id > 0 {
+		query["id"] = strconv.FormatInt(id, 10)
+	}
+	var resp ResDepartmentList
+	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiWorkCgiBin.WithPath("department/list")).
+		SetAccessTokenFrom(c.Context).
+		SetQuery(query).
+		BindJson(&resp).
+		Debug(c.IsDebug(), c.Logger()).
+		Do(); err != nil {
+		return nil, c.ErrorWith("department_list", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.DepartmentList(id)
+		}
+		return nil, c.ErrorFrom("department_list", resp.WxResponse)
+	}
+	return resp.Department, nil
+}
+
+type User struct {
+	Userid         string   `json:"userid"`
+	Name           string   `json:"name"`
+	Department     []int64  `json:"department"`
+	Order          []int64  `json:"order,omitempty"`
+	Position       string   `json:"position,omitempty"`
+	Mobile         string   `json:"mobile,omitempty"`
+	Gender         string   `json:"gender,omitempty"`
+	Email          string   `json:"email,omitempty"`
+	BizMail        string   `json:"biz_mail,omitempty"`
+	IsLeaderInDept []int    `json:"is_leader_in_dept,omitempty"`
+	DirectLeader   []string `json:"direct_leader,omitempty"`
+	Avatar         string   `json:"avatar,omitempty"`
+	Telephone      string   `json:"telephone,omitempty"`
+	Alias          string   `json:"alias,omitempty"`
+	Address        string   `json:"address,omitempty"`
+	Status         int      `json:"status"`
+	MainDepartment int64    `json:"main_department,omitempty"`
+}
+type ResUserList struct {
+	zwx.WxResponse
+	Userlist []User `json:"userlist"`
+}
+
+// UserList
+// @Description: 获取部门成员详情，不包含子部门
+// @receiver c
+// @param departmentId
+// @return []User
+// @return error
+func (c *Context) UserList(departmentId int64) ([]User, error) {
+	var resp ResUserList
+	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiWorkCgiBin.WithPath("user/list")).
+		SetAccessTokenFrom(c.Context).
+		SetQuery(map[string]string{
+			"department_id": strconv.FormatInt(departmentId, 10),
+		}).
+		BindJson(&resp).
+		Debug(c.IsDebug(), c.Logger()).
+		Do(); err != nil {
+		return nil, c.ErrorWith("user_list", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.UserList(departmentId)
+		}
+		return nil, c.ErrorFrom("user_list", resp.WxResponse)
+	}
+	return resp.Userlist, nil
+}
+
+type Tag struct {
+	Tagid   int64  `json:"tagid"`
+	Tagname string `json:"tagname"`
+}
+type ResTagList struct {
+	zwx.WxResponse
+	Taglist []Tag `json:"taglist"`
+}
+
+// TagList
+// @Description: 获取标签列表
+// @receiver c
+// @return []Tag
+// @return error
+func (c *Context) TagList() ([]Tag, error) {
+	var resp ResTagList
+	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiWorkCgiBin.WithPath("tag/list")).
+		SetAccessTokenFrom(c.Context).
+		BindJson(&resp).
+		Debug(c.IsDebug(), c.Logger()).
+		Do(); err != nil {
+		return nil, c.ErrorWith("tag_list", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.TagList()
+		}
+		return nil, c.ErrorFrom("tag_list", resp.WxResponse)
+	}
+	return resp.Taglist, nil
+}
+
+type ResTagUsers struct {
+	zwx.WxResponse
+	Tagname  string `json:"tagname"`
+	Userlist []struct {
+		Userid string `json:"userid"`
+		Name   string `json:"name"`
+	} `json:"userlist"`
+	Partylist []int64 `json:"partylist"`
+}
+
+// TagUsers
+// @Description: 获取标签成员
+// @receiver c
+// @param tagid
+// @return *ResTagUsers
+// @return error
+func (c *Context) TagUsers(tagid int64) (*ResTagUsers, error) {
+	var resp ResTagUsers
+	if err := zwx.NewHttp(zwx.MethodGet, zwx.ApiWorkCgiBin.WithPath("tag/get")).
+		SetAccessTokenFrom(c.Context).
+		SetQuery(map[string]string{
+			"tagid": strconv.FormatInt(tagid, 10),
+		}).
+		BindJson(&resp).
+		Debug(c.IsDebug(), c.Logger()).
+		Do(); err != nil {
+		return nil, c.ErrorWith("tag_get", err)
+	}
+	if resp.Errcode != 0 {
+		if c.RetryAccessToken(resp.Errcode) {
+			return c.TagUsers(tagid)
+		}
+		return nil, c.ErrorFrom("tag_get", resp.WxResponse)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,65 @@
+package wxwork
+
+import (
+	"github.com/zohu/zwx/utils"
+	"strconv"
+)
+
+type JsSdkConfig struct {
+	AppId     string `json:"appId"`
+	Timestamp int64  `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	Signature string `json:"signature"`
+}
+
+type AgentConfig struct {
+	Corpid    string `json:"corpid"`
+	Agentid   string `json:"agentid"`
+	Timestamp int64  `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	Signature string `json:"signature"`
+}
+
+// JsSdkConfig
+// @Description: 生成wx.config所需的签名参数，使用企业级jsapi_ticket
+// @receiver c
+// @param pageUrl 当前网页的完整URL，#及其后面部分会被去掉
+// @return *JsSdkConfig
+// @return error
+func (c *Context) JsSdkConfig(pageUrl string) (*JsSdkConfig, error) {
+	c.AccessToken()
+	if c.JsTicket() == "" {
+		return nil, c.Error("jsapi_ticket", "ticket not ready")
+	}
+	cfg := &JsSdkConfig{
+		AppId:     c.AppidMain(),
+		Timestamp: utils.Now().Unix(),
+		NonceStr:  utils.RandomStr(16),
+	}
+	cfg.Signature = utils.JsapiSignature(c.JsTicket(), cfg.NonceStr, strconv.FormatInt(cfg.Timestamp, 10), pageUrl)
+	return cfg, nil
+}
+
+// AgentConfig
+// @Description: 生成wx.agentConfig所需的签名参数，使用应用级jsapi_ticket，APP需配置agent_id
+// @receiver c
+// @param pageUrl 当前网页的完整URL，#及其后面部分会被去掉
+// @return *AgentConfig
+// @return error
+func (c *Context) AgentConfig(pageUrl string) (*AgentConfig, error) {
+	if c.AgentId() == "" {
+		return nil, c.Error("agent_config", "agent_id is required")
+	}
+	c.AccessToken()
+	if c.AgentTicket() == "" {
+		return nil, c.Error("agent_config", "ticket not ready")
+	}
+	cfg := &AgentConfig{
+		Corpid:    c.AppidMain(),
+		Agentid:   c.AgentId(),
+		Timestamp: utils.Now().Unix(),
+		NonceStr:  utils.RandomStr(16),
+	}
+	cfg.Signature = utils.JsapiSignature(c.AgentTicket(), cfg.NonceStr, strconv.FormatInt(cfg.Timestamp, 10), pageUrl)
+	return cfg, nil
+}
@@ -0,0 +1,85 @@
+package wxwork
+
+import (
+	"github.com/zohu/zwx"
+	"github.com/zohu/zwx/internal/zwxtest"
+	"github.com/zohu/zwx/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+const pageUrl = "https://work.example.com/p?x=1#top"
+
+// setupTest 托管已有token和ticket的企业微信应用，时钟固定，不请求微信接口
+func setupTest(t *testing.T, apps ...zwx.App) {
+	t.Helper()
+	for i := range apps {
+		apps[i].AppType = zwx.TypeWxWork
+		apps[i].AppSecret = "secret"
+		apps[i].AccessToken = "token"
+	}
+	zwxtest.Setup(t, time.Unix(1700000000, 0), apps...)
+}
+
+func TestJsSdkAndAgentConfig(t *testing.T) {
+	setupTest(t,
+		zwx.App{Appid: "corp1", AgentId: "1000002", JsTicket: "corp-ticket", AgentTicket: "agent-ticket"},
+		zwx.App{Appid: "corp2", JsTicket: "corp-ticket", AgentTicket: "agent-ticket"},
+		zwx.App{Appid: "corp3", AgentId: "1000003"},
+	)
+	tests := []struct {
+		name     string
+		appid    string
+		agent    bool
+		wantSign string // 签名使用的ticket
+		wantErr  string
+	}{
+		{"corp config", "corp1", false, "corp-ticket", ""},
+		{"agent config", "corp1", true, "agent-ticket", ""},
+		{"agent config without agent_id", "corp2", true, "", "agent_id is required"},
+		{"ticket not ready", "corp3", true, "", "ticket not ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := App(tt.appid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var nonce, sign string
+			var ts int64
+			if tt.agent {
+				var cfg *AgentConfig
+				if cfg, err = c.AgentConfig(pageUrl); err == nil {
+					if cfg.Corpid != tt.appid || cfg.Agentid != c.AgentId() {
+						t.Errorf("corpid/agentid = %s/%s", cfg.Corpid, cfg.Agentid)
+					}
+					nonce, sign, ts = cfg.NonceStr, cfg.Signature, cfg.Timestamp
+				}
+			} else {
+				var cfg *JsSdkConfig
+				if cfg, err = c.JsSdkConfig(pageUrl); err == nil {
+					if cfg.AppId != tt.appid {
+						t.Errorf("appId = %s", cfg.AppId)
+					}
+					nonce, sign, ts = cfg.NonceStr, cfg.Signature, cfg.Timestamp
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts != 1700000000 {
+				t.Errorf("timestamp = %d", ts)
+			}
+			if want := utils.JsapiSignature(tt.wantSign, nonce, "1700000000", "https://work.example.com/p?x=1"); sign != want {
+				t.Errorf("signature = %s, want %s", sign, want)
+			}
+		})
+	}
+}